@@ -8,6 +8,7 @@ require (
 	github.com/coinbase/rosetta-cli v0.10.3
 	github.com/coinbase/rosetta-sdk-go v0.8.3
 	github.com/coinbase/rosetta-sdk-go/types v1.0.0
+	github.com/dgraph-io/badger/v4 v4.2.0
 	github.com/oasisprotocol/oasis-core/go v0.2400.0
 	google.golang.org/grpc v1.62.1
 )
@@ -30,7 +31,6 @@ require (
 	github.com/consensys/gnark-crypto v0.5.3 // indirect
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0 // indirect
 	github.com/dgraph-io/badger/v2 v2.2007.4 // indirect
-	github.com/dgraph-io/badger/v4 v4.2.0 // indirect
 	github.com/dgraph-io/ristretto v0.1.1 // indirect
 	github.com/dgryski/go-farm v0.0.0-20200201041132-a6ae2369ad13 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -19,6 +19,7 @@ import (
 	"github.com/oasisprotocol/oasis-rosetta-gateway/common"
 	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
 	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
 )
 
 // GatewayPortEnvVar is the name of the environment variable that specifies
@@ -38,9 +39,34 @@ var (
 	versionFlag = flag.Bool("version", false, "Print version and exit")
 )
 
+// RouterConfig configures the optional features of the router returned by
+// NewBlockchainRouter.
+type RouterConfig struct {
+	// BlockTxLimit is the number of transactions above which /block only
+	// returns transaction identifiers (unlimited if zero).
+	BlockTxLimit int
+
+	// WithIndexer enables the endpoints that depend on the transaction
+	// indexer.  The events endpoint is only included if the store is also
+	// persistent, so that event sequence numbers survive restarts.
+	WithIndexer bool
+
+	// SearchMaxTotalCount is the number of matches above which
+	// /search/transactions stops counting (the default if zero).
+	SearchMaxTotalCount int
+
+	// Construction configures the Construction API and the extension
+	// endpoints.
+	Construction services.ConstructionConfig
+}
+
 // NewBlockchainRouter returns a Mux http.Handler from a collection of
 // Rosetta service controllers.
-func NewBlockchainRouter(oasisClient oasis.Client) (http.Handler, error) {
+func NewBlockchainRouter(
+	oasisClient oasis.Client,
+	store *storage.Store,
+	cfg *RouterConfig,
+) (http.Handler, error) {
 	chainID, err := oasisClient.GetChainID(context.Background())
 	if err != nil {
 		return nil, err
@@ -67,25 +93,51 @@ func NewBlockchainRouter(oasisClient oasis.Client) (http.Handler, error) {
 		services.NewNetworkAPIService(oasisClient), asserter,
 	)
 	accountAPIController := server.NewAccountAPIController(
-		services.NewAccountAPIService(oasisClient), asserter,
+		services.NewAccountAPIService(oasisClient, store), asserter,
 	)
 	blockAPIController := server.NewBlockAPIController(
-		services.NewBlockAPIService(oasisClient), asserter,
+		services.NewBlockAPIService(oasisClient, store, cfg.BlockTxLimit), asserter,
 	)
 	constructionAPIController := server.NewConstructionAPIController(
-		services.NewConstructionAPIService(oasisClient), asserter,
+		services.NewConstructionAPIService(oasisClient, &cfg.Construction), asserter,
 	)
 	mempoolAPIController := server.NewMempoolAPIController(
-		services.NewMempoolAPIService(oasisClient), asserter,
+		services.NewMempoolAPIService(oasisClient, cfg.Construction.Mempool), asserter,
 	)
 
-	return server.NewRouter(
+	extensionsAPIController := services.NewExtensionsAPIController(
+		services.NewExtensionsAPIService(oasisClient, &cfg.Construction),
+	)
+
+	routers := []server.Router{
 		networkAPIController,
 		accountAPIController,
 		blockAPIController,
 		constructionAPIController,
 		mempoolAPIController,
-	), nil
+		extensionsAPIController,
+	}
+
+	if cfg.WithIndexer {
+		searchAPIController := server.NewSearchAPIController(
+			services.NewSearchAPIService(oasisClient, store, int64(cfg.SearchMaxTotalCount)), asserter,
+		)
+		routers = append(routers, searchAPIController)
+
+		switch store.Persistent() {
+		case true:
+			eventsAPIController := server.NewEventsAPIController(
+				services.NewEventsAPIService(oasisClient, store), asserter,
+			)
+			routers = append(routers, eventsAPIController)
+		case false:
+			logger.Warn("events API disabled, since the local storage isn't persistent",
+				"data_dir_env_var", storage.DataDirEnvVar,
+			)
+		}
+	}
+
+	return server.NewRouter(routers...), nil
 }
 
 // NewOfflineBlockchainRouter is the same as above, but for offline mode.
@@ -107,7 +159,9 @@ func NewOfflineBlockchainRouter(chainID string) (http.Handler, error) {
 		return nil, err
 	}
 
-	constructionAPIController := server.NewConstructionAPIController(services.NewConstructionAPIService(nil), asserter)
+	constructionAPIController := server.NewConstructionAPIController(
+		services.NewConstructionAPIService(nil, &services.ConstructionConfig{}), asserter,
+	)
 
 	return server.NewRouter(constructionAPIController), nil
 }
@@ -142,6 +196,24 @@ func getPortOrExit() int {
 	return port
 }
 
+// Return the value of the given non-negative integer environment variable
+// (or zero if it is unset) or exit if it is malformed.
+func getUintEnvVarOrExit(name string) int {
+	valueStr := os.Getenv(name)
+	if valueStr == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil || value < 0 {
+		logger.Error("malformed environment variable",
+			"err", err,
+			"name", name,
+		)
+		os.Exit(1)
+	}
+	return value
+}
+
 // Print version information.
 func printVersionInfo() {
 	fmt.Printf("Software version: %s\n", common.SoftwareVersion)
@@ -170,6 +242,7 @@ func main() {
 
 	var chainID string
 	var oasisClient oasis.Client
+	var store *storage.Store
 	var err error
 
 	// Check if we should run in offline mode.
@@ -212,6 +285,16 @@ func main() {
 			)
 			os.Exit(1)
 		}
+
+		// Open local storage.
+		store, err = storage.Open(os.Getenv(storage.DataDirEnvVar))
+		if err != nil {
+			logger.Error("failed to open local storage",
+				"err", err,
+			)
+			os.Exit(1)
+		}
+		defer store.Close()
 	}
 
 	// Set the chain context for preparing signing payloads.
@@ -224,7 +307,31 @@ func main() {
 		router, err = NewOfflineBlockchainRouter(chainID)
 	case false:
 		logger.Info("connected to Oasis node", "chain_context", chainID)
-		router, err = NewBlockchainRouter(oasisClient)
+		cfg := &RouterConfig{
+			BlockTxLimit:        getUintEnvVarOrExit(services.BlockTxLimitEnvVar),
+			WithIndexer:         os.Getenv(services.IndexerEnvVar) != "",
+			SearchMaxTotalCount: getUintEnvVarOrExit(services.SearchMaxTotalCountEnvVar),
+		}
+		if cfg.WithIndexer {
+			logger.Info("starting transaction indexer")
+			go services.NewIndexer(oasisClient, store).Run(context.Background())
+		}
+		mempool := services.NewMempool(oasisClient)
+		go mempool.Run(context.Background())
+		cfg.Construction.Mempool = mempool
+		if os.Getenv(services.TrackerEnvVar) != "" {
+			logger.Info("starting transaction tracker")
+			rebroadcastRetries := getUintEnvVarOrExit(services.RebroadcastRetriesEnvVar)
+			retention := time.Duration(getUintEnvVarOrExit(services.TrackerRetentionEnvVar)) * time.Second
+			tracker := services.NewTracker(oasisClient, store, mempool, rebroadcastRetries, retention)
+			go tracker.Run(context.Background())
+			cfg.Construction.Tracker = tracker
+		}
+		cfg.Construction.SubmitPreflight = os.Getenv(services.SubmitPreflightEnvVar) != ""
+		cfg.Construction.NonceReservationTTL = time.Duration(
+			getUintEnvVarOrExit(services.NonceReservationTTLEnvVar),
+		) * time.Second
+		router, err = NewBlockchainRouter(oasisClient, store, cfg)
 	}
 	if err != nil {
 		logger.Error("unable to create Rosetta blockchain router", "err", err)
@@ -2,17 +2,22 @@ package oasis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"sync"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/oasisprotocol/oasis-core/go/common/cache/lru"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
 	cmnGrpc "github.com/oasisprotocol/oasis-core/go/common/grpc"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
 	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
 	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
 	control "github.com/oasisprotocol/oasis-core/go/control/api"
@@ -23,12 +28,29 @@ import (
 // height.
 const LatestHeight = consensus.HeightLatest
 
+// DefaultBlockHashLookback is the default maximum number of most recent
+// blocks that are searched when looking up a block by its hash.  Each searched
+// block costs one GetBlock call to the node.
+const DefaultBlockHashLookback = 100
+
+// BlockHashLookbackEnvVar is the name of the environment variable that
+// overrides DefaultBlockHashLookback.
+const BlockHashLookbackEnvVar = "OASIS_ROSETTA_GATEWAY_BLOCK_HASH_LOOKBACK"
+
+// missingBlockCacheSize is the number of unknown block hashes for which the
+// client remembers how far it has already searched.
+const missingBlockCacheSize = 1000
+
 // GrpcAddrEnvVar is the name of the environment variable that specifies the
 // gRPC host address of the Oasis node that the client should connect to.
 const GrpcAddrEnvVar = "OASIS_NODE_GRPC_ADDR"
 
 var logger = logging.GetLogger("oasis")
 
+// ErrBlockNotFound is the error returned when a block with the given hash
+// could not be found.
+var ErrBlockNotFound = errors.New("oasis: block not found")
+
 // Client can be used to query an Oasis node for information and to submit
 // transactions.
 type Client interface {
@@ -39,6 +61,12 @@ type Client interface {
 	// GetBlock returns the Oasis block at given height.
 	GetBlock(ctx context.Context, height int64) (*Block, error)
 
+	// GetBlockHeightByHash returns the height of the Oasis block with the
+	// given hash.  Only the most recent blocks are searched (see
+	// BlockHashLookbackEnvVar), and none below minHeight.  Blocks that have
+	// already been searched for an unknown hash aren't searched again.
+	GetBlockHeightByHash(ctx context.Context, blkHash hash.Hash, minHeight int64) (int64, error)
+
 	// GetLatestBlock returns latest Oasis block.
 	GetLatestBlock(ctx context.Context) (*Block, error)
 
@@ -76,12 +104,25 @@ type Client interface {
 	// SubmitTxNoWait submits the given signed transaction to the node.
 	SubmitTxNoWait(ctx context.Context, tx *transaction.SignedTransaction) error
 
+	// SubmitTxWithProof submits the given signed transaction to the node and
+	// waits for it to be included in a block.  It returns the proof of
+	// inclusion, which contains the block height.  Transactions whose
+	// execution failed are reported as errors, even though they are included.
+	SubmitTxWithProof(ctx context.Context, tx *transaction.SignedTransaction) (*transaction.Proof, error)
+
 	// GetNextNonce returns the nonce that should be used when signing the next
 	// transaction for the given account address at given height.
 	GetNextNonce(ctx context.Context, addr staking.Address, height int64) (uint64, error)
 
 	// GetStatus returns the status overview of the node.
 	GetStatus(ctx context.Context) (*control.Status, error)
+
+	// EstimateGas returns the amount of gas required to execute the given
+	// transaction signed by the given signer.
+	EstimateGas(ctx context.Context, signer signature.PublicKey, tx *transaction.Transaction) (transaction.Gas, error)
+
+	// GetMinGasPrice returns the minimum gas price accepted by the node.
+	GetMinGasPrice(ctx context.Context) (*quantity.Quantity, error)
 }
 
 // Block is a representation of the Oasis block metadata, converted to be more
@@ -107,6 +148,12 @@ type grpcClient struct {
 
 	// Cached genesis height.
 	genesisHeight int64
+
+	// Maximum number of blocks searched by GetBlockHeightByHash.
+	blockHashLookback int64
+
+	// Latest height searched for each unknown block hash.
+	missingBlocks *lru.Cache
 }
 
 // connect() returns a gRPC connection to Oasis node via its internal socket.
@@ -226,6 +273,48 @@ func (c *grpcClient) GetBlock(ctx context.Context, height int64) (*Block, error)
 	}, nil
 }
 
+func (c *grpcClient) GetBlockHeightByHash(ctx context.Context, blkHash hash.Hash, minHeight int64) (int64, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	client := consensus.NewConsensusClient(conn)
+	blk, err := client.GetBlock(ctx, consensus.HeightLatest)
+	if err != nil {
+		logger.Debug("GetBlockHeightByHash: failed to get latest block", "err", err)
+		return 0, err
+	}
+	latestHeight := blk.Height
+
+	if minHeight < blk.Height-c.blockHashLookback+1 {
+		minHeight = blk.Height - c.blockHashLookback + 1
+	}
+	if minHeight < c.genesisHeight {
+		minHeight = c.genesisHeight
+	}
+	if searched, ok := c.missingBlocks.Get(blkHash); ok && minHeight <= searched.(int64) {
+		minHeight = searched.(int64) + 1
+	}
+	for height := blk.Height; height >= minHeight; height-- {
+		if height != blk.Height {
+			if blk, err = client.GetBlock(ctx, height); err != nil {
+				logger.Debug("GetBlockHeightByHash: failed to get block",
+					"height", height,
+					"err", err,
+				)
+				return 0, err
+			}
+		}
+		if blk.Hash.Equal(&blkHash) {
+			return blk.Height, nil
+		}
+	}
+	if err = c.missingBlocks.Put(blkHash, latestHeight); err != nil {
+		logger.Debug("GetBlockHeightByHash: failed to cache missing block", "err", err)
+	}
+	return 0, ErrBlockNotFound
+}
+
 func (c *grpcClient) GetLatestBlock(ctx context.Context) (*Block, error) {
 	return c.GetBlock(ctx, consensus.HeightLatest)
 }
@@ -317,6 +406,18 @@ func (c *grpcClient) SubmitTxNoWait(ctx context.Context, tx *transaction.SignedT
 	return client.SubmitTxNoWait(ctx, tx)
 }
 
+func (c *grpcClient) SubmitTxWithProof(
+	ctx context.Context,
+	tx *transaction.SignedTransaction,
+) (*transaction.Proof, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client := consensus.NewConsensusClient(conn)
+	return client.SubmitTxWithProof(ctx, tx)
+}
+
 func (c *grpcClient) GetNextNonce(ctx context.Context, addr staking.Address, height int64) (uint64, error) {
 	conn, err := c.connect(ctx)
 	if err != nil {
@@ -338,7 +439,48 @@ func (c *grpcClient) GetStatus(ctx context.Context) (*control.Status, error) {
 	return client.GetStatus(ctx)
 }
 
+func (c *grpcClient) EstimateGas(
+	ctx context.Context,
+	signer signature.PublicKey,
+	tx *transaction.Transaction,
+) (transaction.Gas, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	client := consensus.NewConsensusClient(conn)
+	return client.EstimateGas(ctx, &consensus.EstimateGasRequest{
+		Signer:      signer,
+		Transaction: tx,
+	})
+}
+
+func (c *grpcClient) GetMinGasPrice(ctx context.Context) (*quantity.Quantity, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client := consensus.NewConsensusClient(conn)
+	return client.MinGasPrice(ctx)
+}
+
 // New creates a new Oasis gRPC client.
 func New() (Client, error) {
-	return &grpcClient{}, nil
+	lookback := int64(DefaultBlockHashLookback)
+	if lookbackStr := os.Getenv(BlockHashLookbackEnvVar); lookbackStr != "" {
+		var err error
+		if lookback, err = strconv.ParseInt(lookbackStr, 10, 64); err != nil || lookback < 0 {
+			return nil, fmt.Errorf("malformed %s environment variable: '%s'", BlockHashLookbackEnvVar, lookbackStr)
+		}
+	}
+
+	missingBlocks, err := lru.New(lru.Capacity(missingBlockCacheSize, false))
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcClient{
+		blockHashLookback: lookback,
+		missingBlocks:     missingBlocks,
+	}, nil
 }
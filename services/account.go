@@ -8,14 +8,27 @@ import (
 	"github.com/coinbase/rosetta-sdk-go/types"
 
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
 	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
 
 	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
 )
 
 // SubAccountEscrow specifies the name of the escrow subaccount.
 const SubAccountEscrow = "escrow"
 
+// SubAccountEscrowActive specifies the name of the active escrow subaccount.
+const SubAccountEscrowActive = "escrow:active"
+
+// SubAccountEscrowDebonding specifies the name of the debonding escrow
+// subaccount.
+const SubAccountEscrowDebonding = "escrow:debonding"
+
+// SubAccountDelegated specifies the name of the subaccount holding the value
+// of the account's outgoing active delegations.
+const SubAccountDelegated = "delegated"
+
 // ActiveBalanceKey is the name of the key in the Metadata map inside
 // the response of an account balance request for an escrow account.
 // The value in the Metadata map specifies how many token base units are in
@@ -52,16 +65,30 @@ const DelegationsKey = "delegations"
 // call.
 const DebondingDelegationsKey = "debonding_delegations"
 
+// DelegatedToKey is the name of the key in the Metadata map inside
+// the response of an account balance request for a delegated account.
+// The value in the Metadata map specifies the shares and their value in token
+// base units for each escrow account the account has delegated to.
+const DelegatedToKey = "delegated_to"
+
+// delegatedBalance is the value of a delegation to a single escrow account.
+type delegatedBalance struct {
+	Shares  string `json:"shares"`
+	Balance string `json:"balance"`
+}
+
 var loggerAcct = logging.GetLogger("services/account")
 
 type accountAPIService struct {
 	oasisClient oasis.Client
+	store       *storage.Store
 }
 
 // NewAccountAPIService creates a new instance of an AccountAPIService.
-func NewAccountAPIService(oasisClient oasis.Client) server.AccountAPIServicer {
+func NewAccountAPIService(oasisClient oasis.Client, store *storage.Store) server.AccountAPIServicer {
 	return &accountAPIService{
 		oasisClient: oasisClient,
+		store:       store,
 	}
 }
 
@@ -75,14 +102,10 @@ func (s *accountAPIService) AccountBalance(
 		return nil, err
 	}
 
-	height := oasis.LatestHeight
-	if request.BlockIdentifier != nil {
-		if request.BlockIdentifier.Index != nil {
-			height = *request.BlockIdentifier.Index
-		} else if request.BlockIdentifier.Hash != nil {
-			loggerAcct.Error("AccountBalance: must query block by index")
-			return nil, ErrMustQueryByIndex
-		}
+	height, terr := GetBlockHeight(ctx, s.oasisClient, s.store, request.BlockIdentifier)
+	if terr != nil {
+		loggerAcct.Error("AccountBalance: unable to resolve block identifier", "err", terr.Message)
+		return nil, terr
 	}
 
 	if request.AccountIdentifier.Address == "" {
@@ -96,10 +119,15 @@ func (s *accountAPIService) AccountBalance(
 		return nil, ErrInvalidAccountAddress
 	}
 
-	if request.AccountIdentifier.SubAccount != nil &&
-		request.AccountIdentifier.SubAccount.Address != SubAccountEscrow {
-		loggerAcct.Error("AccountBalance: invalid subaccount", "sub_account", request.AccountIdentifier.SubAccount)
-		return nil, ErrMustSpecifySubAccount
+	var subAccount string
+	if request.AccountIdentifier.SubAccount != nil {
+		subAccount = request.AccountIdentifier.SubAccount.Address
+		switch subAccount {
+		case SubAccountEscrow, SubAccountEscrowActive, SubAccountEscrowDebonding, SubAccountDelegated:
+		default:
+			loggerAcct.Error("AccountBalance: invalid subaccount", "sub_account", request.AccountIdentifier.SubAccount)
+			return nil, ErrMustSpecifySubAccount
+		}
 	}
 
 	act, err := s.oasisClient.GetAccount(ctx, height, owner)
@@ -120,15 +148,19 @@ func (s *accountAPIService) AccountBalance(
 		)
 		return nil, ErrUnableToGetBlk
 	}
+	if terr = CheckBlockIdentifier(s.store, blk, request.BlockIdentifier); terr != nil {
+		return nil, terr
+	}
 
 	md := make(map[string]interface{})
 	md[NonceKey] = act.General.Nonce
 
 	var value string
 
-	if request.AccountIdentifier.SubAccount == nil {
+	switch subAccount {
+	case "":
 		value = act.General.Balance.String()
-	} else {
+	case SubAccountEscrow:
 		// Total is Active + Debonding.
 		total := act.Escrow.Active.Balance.Clone()
 		if err := total.Add(&act.Escrow.Debonding.Balance); err != nil {
@@ -148,26 +180,36 @@ func (s *accountAPIService) AccountBalance(
 		md[DebondingBalanceKey] = act.Escrow.Debonding.Balance.String()
 		md[DebondingSharesKey] = act.Escrow.Debonding.TotalShares.String()
 
-		delegations, err := s.oasisClient.GetDelegations(ctx, height, owner)
-		if err != nil {
-			loggerAcct.Error("AccountBalance: unable to get delegations",
-				"account_id", owner.String(),
-				"height", height,
-				"err", err,
-			)
-			return nil, ErrUnableToGetAccount
+		if terr = s.addDelegations(ctx, height, owner, md); terr != nil {
+			return nil, terr
 		}
-		md[DelegationsKey] = delegations
-		debondingDelegations, err := s.oasisClient.GetDebondingDelegations(ctx, height, owner)
-		if err != nil {
-			loggerAcct.Error("AccountBalance: unable to get debonding delegations",
-				"account_id", owner.String(),
-				"height", height,
-				"err", err,
-			)
-			return nil, ErrUnableToGetAccount
+		if terr = s.addDebondingDelegations(ctx, height, owner, md); terr != nil {
+			return nil, terr
 		}
-		md[DebondingDelegationsKey] = debondingDelegations
+	case SubAccountEscrowActive:
+		value = act.Escrow.Active.Balance.String()
+
+		md[ActiveSharesKey] = act.Escrow.Active.TotalShares.String()
+
+		if terr = s.addDelegations(ctx, height, owner, md); terr != nil {
+			return nil, terr
+		}
+	case SubAccountEscrowDebonding:
+		value = act.Escrow.Debonding.Balance.String()
+
+		md[DebondingSharesKey] = act.Escrow.Debonding.TotalShares.String()
+
+		if terr = s.addDebondingDelegations(ctx, height, owner, md); terr != nil {
+			return nil, terr
+		}
+	case SubAccountDelegated:
+		var delegated map[string]*delegatedBalance
+		value, delegated, terr = s.getDelegatedBalance(ctx, height, owner)
+		if terr != nil {
+			return nil, terr
+		}
+
+		md[DelegatedToKey] = delegated
 	}
 
 	resp := &types.AccountBalanceResponse{
@@ -194,6 +236,106 @@ func (s *accountAPIService) AccountBalance(
 	return resp, nil
 }
 
+// addDelegations adds the delegations to the given escrow account to the
+// balance metadata.
+func (s *accountAPIService) addDelegations(
+	ctx context.Context,
+	height int64,
+	owner staking.Address,
+	md map[string]interface{},
+) *types.Error {
+	delegations, err := s.oasisClient.GetDelegations(ctx, height, owner)
+	if err != nil {
+		loggerAcct.Error("AccountBalance: unable to get delegations",
+			"account_id", owner.String(),
+			"height", height,
+			"err", err,
+		)
+		return ErrUnableToGetAccount
+	}
+	md[DelegationsKey] = delegations
+	return nil
+}
+
+// addDebondingDelegations adds the debonding delegations to the given escrow
+// account to the balance metadata.
+func (s *accountAPIService) addDebondingDelegations(
+	ctx context.Context,
+	height int64,
+	owner staking.Address,
+	md map[string]interface{},
+) *types.Error {
+	debondingDelegations, err := s.oasisClient.GetDebondingDelegations(ctx, height, owner)
+	if err != nil {
+		loggerAcct.Error("AccountBalance: unable to get debonding delegations",
+			"account_id", owner.String(),
+			"height", height,
+			"err", err,
+		)
+		return ErrUnableToGetAccount
+	}
+	md[DebondingDelegationsKey] = debondingDelegations
+	return nil
+}
+
+// getDelegatedBalance returns the total value of the given account's outgoing
+// active delegations together with the value of each delegation.
+func (s *accountAPIService) getDelegatedBalance(
+	ctx context.Context,
+	height int64,
+	owner staking.Address,
+) (string, map[string]*delegatedBalance, *types.Error) {
+	delegations, err := s.oasisClient.GetDelegations(ctx, height, owner)
+	if err != nil {
+		loggerAcct.Error("AccountBalance: unable to get delegations",
+			"account_id", owner.String(),
+			"height", height,
+			"err", err,
+		)
+		return "", nil, ErrUnableToGetAccount
+	}
+
+	total := quantity.NewQuantity()
+	delegated := make(map[string]*delegatedBalance, len(delegations))
+	for escrowAddr, delegation := range delegations {
+		escrowAct, err := s.oasisClient.GetAccount(ctx, height, escrowAddr)
+		if err != nil {
+			loggerAcct.Error("AccountBalance: unable to get escrow account",
+				"account_id", owner.String(),
+				"escrow_account_id", escrowAddr.String(),
+				"height", height,
+				"err", err,
+			)
+			return "", nil, ErrUnableToGetAccount
+		}
+		balance, err := escrowAct.Escrow.Active.StakeForShares(&delegation.Shares)
+		if err != nil {
+			loggerAcct.Error("AccountBalance: delegated: unable to compute delegation value",
+				"account_id", owner.String(),
+				"escrow_account_id", escrowAddr.String(),
+				"height", height,
+				"shares", delegation.Shares.String(),
+				"err", err,
+			)
+			return "", nil, ErrMalformedValue
+		}
+		if err = total.Add(balance); err != nil {
+			loggerAcct.Error("AccountBalance: delegated: unable to add delegation value",
+				"account_id", owner.String(),
+				"escrow_account_id", escrowAddr.String(),
+				"height", height,
+				"err", err,
+			)
+			return "", nil, ErrMalformedValue
+		}
+		delegated[StringFromAddress(escrowAddr)] = &delegatedBalance{
+			Shares:  delegation.Shares.String(),
+			Balance: balance.String(),
+		}
+	}
+	return total.String(), delegated, nil
+}
+
 func (s *accountAPIService) AccountCoins(
 	ctx context.Context,
 	request *types.AccountCoinsRequest,
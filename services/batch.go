@@ -0,0 +1,236 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+)
+
+// BatchIndexKey is the name of the key in the Metadata map inside an
+// operation that specifies the index of the transaction in a batch that the
+// operation belongs to.  Batches are supported by the Construction API only.
+const BatchIndexKey = "batch_index"
+
+// TxHashesKey is the name of the key in the Metadata map inside a
+// TransactionIdentifierResponse that specifies the hashes of all transactions
+// in a batch.
+const TxHashesKey = "hashes"
+
+// splitBatch splits the given operations into the operations of each
+// transaction in a batch.  It returns nil if the operations don't form a
+// batch.
+//
+// The operations of each transaction must be contiguous and the transactions
+// must be in order of their batch index, starting at zero.
+func splitBatch(ops []*types.Operation) ([][]*types.Operation, error) {
+	var groups [][]*types.Operation
+	for i, op := range ops {
+		raw, ok := op.Metadata[BatchIndexKey]
+		if !ok {
+			if groups != nil {
+				return nil, fmt.Errorf("operation %d: batch index not specified", i)
+			}
+			continue
+		}
+		if i > 0 && groups == nil {
+			return nil, fmt.Errorf("operation 0: batch index not specified")
+		}
+		idxF64, ok := raw.(float64)
+		if !ok || idxF64 < 0 || idxF64 != math.Trunc(idxF64) {
+			return nil, fmt.Errorf("operation %d: malformed batch index", i)
+		}
+		switch idx := int(idxF64); idx {
+		case len(groups) - 1:
+			groups[idx] = append(groups[idx], op)
+		case len(groups):
+			groups = append(groups, []*types.Operation{op})
+		default:
+			return nil, fmt.Errorf("operation %d: batch index %d out of order", i, idx)
+		}
+	}
+	return groups, nil
+}
+
+// withBatchIndex sets the batch index of the given operations and shifts
+// their operation identifier indices by the given offset.
+func withBatchIndex(ops []*types.Operation, batchIndex int, offset int64) []*types.Operation {
+	for _, op := range ops {
+		op.OperationIdentifier.Index += offset
+		for _, rel := range op.RelatedOperations {
+			rel.Index += offset
+		}
+		if op.Metadata == nil {
+			op.Metadata = make(map[string]interface{})
+		}
+		op.Metadata[BatchIndexKey] = batchIndex
+	}
+	return ops
+}
+
+// DecodeUnsignedTransactionBatch decodes a batch of unsigned transactions from
+// a Base64-encoded CBOR blob.
+func DecodeUnsignedTransactionBatch(raw string) ([]*UnsignedTransaction, error) {
+	rawBatch, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode failed: %w", err)
+	}
+
+	var batch []*UnsignedTransaction
+	if err := cbor.Unmarshal(rawBatch, &batch); err != nil {
+		return nil, fmt.Errorf("CBOR decode failed: %w", err)
+	}
+	if len(batch) == 0 {
+		return nil, fmt.Errorf("empty batch")
+	}
+	return batch, nil
+}
+
+// DecodeSignedTransactionBatch decodes a batch of signed transactions from a
+// Base64-encoded CBOR blob.
+func DecodeSignedTransactionBatch(raw string) ([]*transaction.SignedTransaction, error) {
+	rawBatch, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode failed: %w", err)
+	}
+
+	var batch []*transaction.SignedTransaction
+	if err := cbor.Unmarshal(rawBatch, &batch); err != nil {
+		return nil, fmt.Errorf("CBOR decode failed: %w", err)
+	}
+	if len(batch) == 0 {
+		return nil, fmt.Errorf("empty batch")
+	}
+	return batch, nil
+}
+
+// decodeUnsignedTransactions decodes a single unsigned transaction or a batch
+// of them.  It also returns whether the blob is a batch.
+func decodeUnsignedTransactions(raw string) ([]*UnsignedTransaction, bool, error) {
+	ut, err := DecodeUnsignedTransaction(raw)
+	if err == nil {
+		return []*UnsignedTransaction{ut}, false, nil
+	}
+	batch, err2 := DecodeUnsignedTransactionBatch(raw)
+	if err2 != nil {
+		return nil, false, err
+	}
+	return batch, true, nil
+}
+
+// decodeSignedTransactions decodes a single signed transaction or a batch of
+// them.  It also returns whether the blob is a batch.
+func decodeSignedTransactions(raw string) ([]*transaction.SignedTransaction, bool, error) {
+	tx, err := DecodeSignedTransaction(raw)
+	if err == nil {
+		return []*transaction.SignedTransaction{tx}, false, nil
+	}
+	batch, err2 := DecodeSignedTransactionBatch(raw)
+	if err2 != nil {
+		return nil, false, err
+	}
+	return batch, true, nil
+}
+
+// txIdentifierResponse returns the response identifying the given signed
+// transactions.  A batch is identified by its first transaction and the
+// hashes of all transactions are listed in the metadata.
+func txIdentifierResponse(txs []*transaction.SignedTransaction, batch bool) *types.TransactionIdentifierResponse {
+	resp := &types.TransactionIdentifierResponse{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: txs[0].Hash().String(),
+		},
+	}
+	if batch {
+		resp.Metadata = map[string]interface{}{
+			TxHashesKey: txHashes(txs),
+		}
+	}
+	return resp
+}
+
+// txHashes returns the hashes of the given signed transactions.
+func txHashes(txs []*transaction.SignedTransaction) []string {
+	hashes := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash().String())
+	}
+	return hashes
+}
+
+// constructedTransactions are the transactions decoded from the operations
+// given to the Construction API.
+type constructedTransactions struct {
+	// signer is the address of the account signing all transactions.
+	signer string
+	// txs are the transactions without nonces.
+	txs []*transaction.Transaction
+	// hasFee is whether the operations of each transaction include a fee.
+	hasFee []bool
+	// batch is whether the operations form a batch.
+	batch bool
+	// reclaimEscrowAccount is the escrow account of a reclaim escrow intent
+	// that specifies an amount.
+	reclaimEscrowAccount *staking.Address
+	// reclaimEscrowAmount is the amount of a reclaim escrow intent that
+	// specifies an amount, if any.
+	reclaimEscrowAmount *quantity.Quantity
+}
+
+// getTransactions decodes a single transaction or a batch of transactions
+// from the given operations.  All transactions in a batch must have the same
+// signer.
+//
+// The shares to reclaim for a reclaim escrow intent that specifies an amount
+// are taken from the given construction metadata.  If the metadata is not
+// known yet (i.e. it is nil), zero shares are used as a placeholder.
+func getTransactions(ops []*types.Operation, md map[string]interface{}) (*constructedTransactions, error) {
+	groups, err := splitBatch(ops)
+	if err != nil {
+		return nil, err
+	}
+	ct := constructedTransactions{
+		batch: groups != nil,
+	}
+	if !ct.batch {
+		groups = [][]*types.Operation{ops}
+	}
+	for i, group := range groups {
+		om := newOperationToTransactionMapper(group)
+		account, amount, err := om.ReclaimEscrowAmount()
+		if err != nil {
+			return nil, err
+		}
+		if amount != nil {
+			if ct.batch {
+				return nil, fmt.Errorf("batch transaction %d: reclaim escrow by amount can't be batched", i)
+			}
+			ct.reclaimEscrowAccount = account
+			ct.reclaimEscrowAmount = amount
+			shares, err2 := reclaimEscrowSharesFromMetadata(md)
+			if err2 != nil {
+				return nil, err2
+			}
+			om.SetReclaimEscrowShares(shares)
+		}
+		signer, tx, err := om.GetTransaction()
+		if err != nil {
+			if ct.batch {
+				return nil, fmt.Errorf("batch transaction %d: %w", i, err)
+			}
+			return nil, err
+		}
+		if i > 0 && signer != ct.signer {
+			return nil, fmt.Errorf("batch transaction %d: signer differs (got: %s expected: %s)", i, signer, ct.signer)
+		}
+		ct.signer = signer
+		ct.txs = append(ct.txs, tx)
+		ct.hasFee = append(ct.hasFee, om.HasFee())
+	}
+	return &ct, nil
+}
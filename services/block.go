@@ -8,24 +8,49 @@ import (
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
 
 	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
 )
 
 // EpochKey is the name of the key in the Metadata map inside the response of a block request.
 // The value in the map is the epoch number of the returned block.
 const EpochKey = "epoch"
 
+// BlockTxLimitEnvVar is the name of the environment variable that specifies
+// the number of transactions above which the /block endpoint only returns the
+// transaction identifiers in other_transactions.  The transactions can then be
+// fetched one by one via the /block/transaction endpoint.
+// If unset or zero, all transactions are always returned in full.
+const BlockTxLimitEnvVar = "OASIS_ROSETTA_GATEWAY_BLOCK_TX_LIMIT"
+
 var loggerBlk = logging.GetLogger("services/block")
 
 type blockAPIService struct {
 	oasisClient oasis.Client
+	store       *storage.Store
+	txLimit     int
 }
 
 // NewBlockAPIService creates a new instance of an AccountAPIService.
-func NewBlockAPIService(oasisClient oasis.Client) server.BlockAPIServicer {
+func NewBlockAPIService(oasisClient oasis.Client, store *storage.Store, txLimit int) server.BlockAPIServicer {
 	return &blockAPIService{
 		oasisClient: oasisClient,
+		store:       store,
+		txLimit:     txLimit,
+	}
+}
+
+// escrowPoolAt returns a function that returns the active pool of an escrow
+// account at the given height.
+func escrowPoolAt(ctx context.Context, oc oasis.Client, height int64) escrowPoolFunc {
+	return func(escrow staking.Address) (*staking.SharePool, error) {
+		act, err := oc.GetAccount(ctx, height, escrow)
+		if err != nil {
+			return nil, err
+		}
+		return &act.Escrow.Active, nil
 	}
 }
 
@@ -40,15 +65,10 @@ func (s *blockAPIService) Block(
 		return nil, terr
 	}
 
-	height := oasis.LatestHeight
-
-	if request.BlockIdentifier != nil {
-		if request.BlockIdentifier.Index != nil {
-			height = *request.BlockIdentifier.Index
-		} else if request.BlockIdentifier.Hash != nil {
-			loggerBlk.Error("Block: must query block by index")
-			return nil, ErrMustQueryByIndex
-		}
+	height, terr := GetBlockHeight(ctx, s.oasisClient, s.store, request.BlockIdentifier)
+	if terr != nil {
+		loggerBlk.Error("Block: unable to resolve block identifier", "err", terr.Message)
+		return nil, terr
 	}
 
 	blk, err := s.oasisClient.GetBlock(ctx, height)
@@ -59,6 +79,9 @@ func (s *blockAPIService) Block(
 		)
 		return nil, ErrUnableToGetBlk
 	}
+	if terr = CheckBlockIdentifier(s.store, blk, request.BlockIdentifier); terr != nil {
+		return nil, terr
+	}
 
 	td := newTransactionsDecoder()
 
@@ -96,7 +119,7 @@ func (s *blockAPIService) Block(
 	var blkHash hash.Hash
 	_ = blkHash.UnmarshalHex(blk.Hash)
 
-	if err = td.DecodeBlock(blkHash, evts); err != nil {
+	if err = td.DecodeBlock(blkHash, evts, escrowPoolAt(ctx, s.oasisClient, blk.Height)); err != nil {
 		loggerBlk.Error("Block: unable to decode block events",
 			"height", height,
 			"err", err,
@@ -104,6 +127,24 @@ func (s *blockAPIService) Block(
 		return nil, ErrUnableToGetTxns
 	}
 
+	txs := td.Transactions()
+	var otherTxs []*types.TransactionIdentifier
+	if s.txLimit > 0 && len(txsWithRes.Transactions) > s.txLimit {
+		// Only return identifiers for busy blocks, the transactions themselves
+		// can be fetched via /block/transaction.  Malformed transactions have
+		// been skipped above, so they are omitted from both.
+		blkTx := td.Transaction(blkHash)
+		blkTxs := []*types.Transaction{}
+		for _, tx := range txs {
+			if tx == blkTx {
+				blkTxs = append(blkTxs, tx)
+				continue
+			}
+			otherTxs = append(otherTxs, tx.TransactionIdentifier)
+		}
+		txs = blkTxs
+	}
+
 	tblk := &types.Block{
 		BlockIdentifier: &types.BlockIdentifier{
 			Index: blk.Height,
@@ -114,14 +155,15 @@ func (s *blockAPIService) Block(
 			Hash:  blk.ParentHash,
 		},
 		Timestamp:    blk.Timestamp,
-		Transactions: td.Transactions(),
+		Transactions: txs,
 		Metadata: map[string]interface{}{
 			EpochKey: blk.Epoch,
 		},
 	}
 
 	resp := &types.BlockResponse{
-		Block: tblk,
+		Block:             tblk,
+		OtherTransactions: otherTxs,
 	}
 
 	jr, _ := json.Marshal(resp)
@@ -131,12 +173,107 @@ func (s *blockAPIService) Block(
 }
 
 // BlockTransaction implements the /block/transaction endpoint.
-// Note: we don't implement this, since we already return all transactions
-// in the /block endpoint response above.
 func (s *blockAPIService) BlockTransaction(
 	ctx context.Context,
 	request *types.BlockTransactionRequest,
 ) (*types.BlockTransactionResponse, *types.Error) {
-	loggerBlk.Error("BlockTransaction: not implemented")
-	return nil, ErrNotImplemented
+	terr := ValidateNetworkIdentifier(ctx, s.oasisClient, request.NetworkIdentifier)
+	if terr != nil {
+		loggerBlk.Error("BlockTransaction: network validation failed", "err", terr.Message)
+		return nil, terr
+	}
+
+	var txHash hash.Hash
+	if err := txHash.UnmarshalHex(request.TransactionIdentifier.Hash); err != nil {
+		loggerBlk.Error("BlockTransaction: malformed transaction hash",
+			"hash", request.TransactionIdentifier.Hash,
+			"err", err,
+		)
+		return nil, ErrMalformedValue
+	}
+
+	height := request.BlockIdentifier.Index
+	blk, err := s.oasisClient.GetBlock(ctx, height)
+	if err != nil {
+		loggerBlk.Error("BlockTransaction: unable to get block",
+			"height", height,
+			"err", err,
+		)
+		return nil, ErrUnableToGetBlk
+	}
+	terr = CheckBlockIdentifier(s.store, blk, &types.PartialBlockIdentifier{
+		Index: &request.BlockIdentifier.Index,
+		Hash:  &request.BlockIdentifier.Hash,
+	})
+	if terr != nil {
+		return nil, terr
+	}
+
+	td := newTransactionsDecoder()
+
+	var blkHash hash.Hash
+	_ = blkHash.UnmarshalHex(blk.Hash)
+
+	switch txHash.Equal(&blkHash) {
+	case true:
+		// Block-level events are put under a "transaction" with the block hash.
+		evts, err := s.oasisClient.GetStakingEvents(ctx, height)
+		if err != nil {
+			loggerBlk.Error("BlockTransaction: unable to get staking events",
+				"height", height,
+				"err", err,
+			)
+			return nil, ErrUnableToGetTxns
+		}
+		if err = td.DecodeBlock(blkHash, evts, escrowPoolAt(ctx, s.oasisClient, blk.Height)); err != nil {
+			loggerBlk.Error("BlockTransaction: unable to decode block events",
+				"height", height,
+				"err", err,
+			)
+			return nil, ErrUnableToGetTxns
+		}
+	case false:
+		txsWithRes, err := s.oasisClient.GetTransactionsWithResults(ctx, height)
+		if err != nil {
+			loggerBlk.Error("BlockTransaction: unable to get transactions",
+				"height", height,
+				"err", err,
+			)
+			return nil, ErrUnableToGetTxns
+		}
+		for i, rawTx := range txsWithRes.Transactions {
+			if rawTxHash := hash.NewFromBytes(rawTx); !rawTxHash.Equal(&txHash) {
+				continue
+			}
+			// Treat malformed transactions the same way as /block does, so
+			// that only the transactions listed there can be found.
+			if err = td.DecodeTx(rawTx, txsWithRes.Results[i]); err != nil {
+				loggerBlk.Warn("BlockTransaction: malformed transaction",
+					"height", height,
+					"index", i,
+					"raw_tx", rawTx,
+					"err", err,
+				)
+			}
+			break
+		}
+	}
+
+	tx := td.Transaction(txHash)
+	if tx == nil {
+		loggerBlk.Error("BlockTransaction: transaction not found",
+			"height", height,
+			"tx_hash", txHash.String(),
+		)
+		return nil, ErrTransactionNotFound
+	}
+
+	resp := &types.BlockTransactionResponse{
+		Transaction: tx,
+	}
+
+	jr, _ := json.Marshal(resp)
+	loggerBlk.Debug("BlockTransaction OK", "response", jr)
+
+	return resp, nil
 }
@@ -2,12 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strings"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
 	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
 
 	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
 )
 
 // OasisBlockchainName is the name of the Oasis blockchain.
@@ -25,6 +30,8 @@ var OasisCurrency = &types.Currency{
 // the node.
 const OfflineModeChainIDEnvVar = "OASIS_ROSETTA_GATEWAY_OFFLINE_MODE_CHAIN_ID"
 
+var loggerCommon = logging.GetLogger("services/common")
+
 // GetChainID returns the chain ID.
 func GetChainID(ctx context.Context, oc oasis.Client) (string, *types.Error) {
 	chainID, err := oc.GetChainID(ctx)
@@ -83,3 +90,102 @@ func StringFromAddress(address staking.Address) string {
 	}
 	return string(buf)
 }
+
+// GetBlockHeight returns the height of the block specified by the given
+// partial block identifier, or oasis.LatestHeight if it is nil.
+//
+// Blocks that are specified only by hash are first looked up in the local
+// block index and then among the most recent blocks on the node.  If the
+// transaction indexer is enabled, the index covers all indexed blocks, so only
+// the blocks that haven't been indexed yet are searched on the node.
+func GetBlockHeight(
+	ctx context.Context,
+	oc oasis.Client,
+	store *storage.Store,
+	pbi *types.PartialBlockIdentifier,
+) (int64, *types.Error) {
+	switch {
+	case pbi == nil:
+		return oasis.LatestHeight, nil
+	case pbi.Index != nil:
+		return *pbi.Index, nil
+	case pbi.Hash == nil:
+		return oasis.LatestHeight, nil
+	}
+
+	var blkHash hash.Hash
+	if err := blkHash.UnmarshalHex(*pbi.Hash); err != nil {
+		loggerCommon.Error("GetBlockHeight: malformed block hash",
+			"hash", *pbi.Hash,
+			"err", err,
+		)
+		return 0, ErrMalformedValue
+	}
+
+	height, ok, err := store.GetBlockHeight(blkHash)
+	if err != nil {
+		loggerCommon.Warn("GetBlockHeight: unable to query block index",
+			"hash", blkHash.Hex(),
+			"err", err,
+		)
+	}
+	if ok {
+		return height, nil
+	}
+
+	// Indexed blocks are all in the block index.
+	var minHeight int64
+	indexedHeight, ok, err := store.GetIndexedHeight()
+	if err != nil {
+		loggerCommon.Warn("GetBlockHeight: unable to query indexed height", "err", err)
+	}
+	if ok {
+		minHeight = indexedHeight + 1
+	}
+
+	height, err = oc.GetBlockHeightByHash(ctx, blkHash, minHeight)
+	switch {
+	case err == nil:
+	case errors.Is(err, oasis.ErrBlockNotFound):
+		loggerCommon.Error("GetBlockHeight: block not found", "hash", blkHash.Hex())
+		return 0, ErrBlockNotFound
+	default:
+		loggerCommon.Error("GetBlockHeight: unable to look up block by hash",
+			"hash", blkHash.Hex(),
+			"err", err,
+		)
+		return 0, ErrUnableToGetBlk
+	}
+	return height, nil
+}
+
+// CheckBlockIdentifier verifies that the given block matches the hash in the
+// given partial block identifier (if any) and records the block in the local
+// block index.
+func CheckBlockIdentifier(
+	store *storage.Store,
+	blk *oasis.Block,
+	pbi *types.PartialBlockIdentifier,
+) *types.Error {
+	if pbi != nil && pbi.Hash != nil && !strings.EqualFold(*pbi.Hash, blk.Hash) {
+		loggerCommon.Error("CheckBlockIdentifier: block hash mismatch",
+			"height", blk.Height,
+			"hash", blk.Hash,
+			"requested_hash", *pbi.Hash,
+		)
+		return ErrBlockHashMismatch
+	}
+
+	var blkHash hash.Hash
+	if err := blkHash.UnmarshalHex(blk.Hash); err != nil {
+		return nil
+	}
+	if err := store.PutBlockHeight(blkHash, blk.Height); err != nil {
+		loggerCommon.Warn("CheckBlockIdentifier: unable to update block index",
+			"height", blk.Height,
+			"hash", blk.Hash,
+			"err", err,
+		)
+	}
+	return nil
+}
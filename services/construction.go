@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -29,6 +30,17 @@ const OptionsIDKey = "id"
 // ConstructionMetadataResponse that specifies the next valid nonce.
 const NonceKey = "nonce"
 
+// OptionsTxKey is the name of the key in the Options map inside a
+// ConstructionMetadataRequest that specifies the Base64 encoded CBOR
+// transaction to estimate the fee for, or a list of them for a batch.
+const OptionsTxKey = "tx"
+
+// FeeAmountKey is the name of the key in the Metadata map inside a
+// ConstructionMetadataResponse that specifies the suggested fee amount in base
+// units.  The estimated gas limit is specified under FeeGasKey.  Both are used
+// by /construction/payloads if the fee operations are omitted.
+const FeeAmountKey = "fee_amount"
+
 // UnsignedTransaction is a transaction with the account that would sign it.
 type UnsignedTransaction struct {
 	Tx     cbor.RawMessage `json:"tx"`
@@ -38,14 +50,44 @@ type UnsignedTransaction struct {
 var loggerCons = logging.GetLogger("services/construction")
 
 type constructionAPIService struct {
-	oasisClient oasis.Client
+	oasisClient     oasis.Client
+	submitPreflight bool
+	tracker         *Tracker
+	nonces          *nonceReservations
+	mempool         *Mempool
+}
+
+// ConstructionConfig configures the optional features of the Construction
+// API services.  The zero value disables all of them.
+type ConstructionConfig struct {
+	// SubmitPreflight enables checking transactions before they are
+	// submitted.
+	SubmitPreflight bool
+
+	// Tracker tracks submitted transactions if it is non-nil.
+	Tracker *Tracker
+
+	// NonceReservationTTL is how long the nonces handed out by
+	// /construction/metadata are reserved for, if it is non-zero.
+	NonceReservationTTL time.Duration
+
+	// Mempool is the mempool snapshot in which the nonce checks look up
+	// pending transactions.
+	Mempool *Mempool
 }
 
 // NewConstructionAPIService creates a new instance of an ConstructionAPIService.
-func NewConstructionAPIService(oasisClient oasis.Client) server.ConstructionAPIServicer {
-	return &constructionAPIService{
-		oasisClient: oasisClient,
+func NewConstructionAPIService(oasisClient oasis.Client, cfg *ConstructionConfig) server.ConstructionAPIServicer {
+	s := &constructionAPIService{
+		oasisClient:     oasisClient,
+		submitPreflight: cfg.SubmitPreflight,
+		tracker:         cfg.Tracker,
+		mempool:         cfg.Mempool,
+	}
+	if cfg.NonceReservationTTL > 0 {
+		s.nonces = newNonceReservations(cfg.NonceReservationTTL)
 	}
+	return s
 }
 
 // ConstructionMetadata implements the /construction/metadata endpoint.
@@ -88,6 +130,39 @@ func (s *constructionAPIService) ConstructionMetadata(
 		return nil, ErrInvalidAccountAddress
 	}
 
+	md := make(map[string]interface{})
+	resp := &types.ConstructionMetadataResponse{
+		Metadata: md,
+	}
+
+	fp, err := parseFeePolicy(request.Options)
+	if err != nil {
+		loggerCons.Error("ConstructionMetadata: malformed fee policy", "err", err)
+		return nil, NewDetailedError(ErrMalformedValue, err)
+	}
+	if fp.maxFee != nil {
+		md[MaxFeeKey] = fp.maxFee.String()
+	}
+
+	// Convert the amount of a reclaim escrow intent to shares.
+	txRaw, hasTx := request.Options[OptionsTxKey]
+	if amountRaw, ok := request.Options[ReclaimEscrowAmountKey]; ok {
+		accountRaw := request.Options[OptionsReclaimEscrowAccountKey]
+		shares, terr := s.reclaimEscrowShares(ctx, owner, accountRaw, amountRaw)
+		if terr != nil {
+			return nil, terr
+		}
+		md[ReclaimEscrowSharesKey] = shares.String()
+
+		// Estimate the fee for the actual shares.
+		if hasTx {
+			if txRaw, err = withReclaimEscrowShares(txRaw, shares); err != nil {
+				loggerCons.Error("ConstructionMetadata: malformed transaction", "err", err)
+				return nil, NewDetailedError(ErrMalformedValue, err)
+			}
+		}
+	}
+
 	nonce, err := s.oasisClient.GetNextNonce(ctx, owner, oasis.LatestHeight)
 	if err != nil {
 		loggerCons.Error("ConstructionMetadata: unable to get next nonce",
@@ -97,12 +172,38 @@ func (s *constructionAPIService) ConstructionMetadata(
 		return nil, ErrUnableToGetNextNonce
 	}
 
+	// Skip the nonces of pending transactions and of concurrent constructions.
+	count := txCount(txRaw)
+	if s.nonces != nil {
+		pending, err := s.mempool.signerNonces(ctx, owner, false)
+		if err != nil {
+			loggerCons.Error("ConstructionMetadata: unable to get unconfirmed transactions", "err", err)
+			return nil, ErrUnableToGetTxns
+		}
+		nonce = s.nonces.reserve(owner, nonce, pending, count)
+	}
+
 	// Return next nonce that should be used to sign transactions for given account.
-	md := make(map[string]interface{})
 	md[NonceKey] = nonce
 
-	resp := &types.ConstructionMetadataResponse{
-		Metadata: md,
+	// Estimate the fee if the transaction and the signer's public key are given.
+	if hasTx && len(request.PublicKeys) > 0 {
+		fee, terr := s.estimateFee(ctx, owner, nonce, txRaw, request.PublicKeys, fp)
+		if terr != nil {
+			// The nonces won't be used, so don't keep them reserved.
+			if s.nonces != nil {
+				s.nonces.release(owner, nonce, count)
+			}
+			return nil, terr
+		}
+		md[FeeGasKey] = fee.Gas
+		md[FeeAmountKey] = fee.Amount.String()
+		resp.SuggestedFee = []*types.Amount{
+			{
+				Value:    fee.Amount.String(),
+				Currency: OasisCurrency,
+			},
+		}
 	}
 
 	jr, _ := json.Marshal(resp)
@@ -111,6 +212,100 @@ func (s *constructionAPIService) ConstructionMetadata(
 	return resp, nil
 }
 
+// estimateFee estimates the gas needed by the given transaction and computes
+// the fee according to the given fee policy.
+func (s *constructionAPIService) estimateFee(
+	ctx context.Context,
+	owner staking.Address,
+	nonce uint64,
+	txRaw interface{},
+	publicKeys []*types.PublicKey,
+	fp *feePolicy,
+) (*transaction.Fee, *types.Error) {
+	var txStrs []interface{}
+	switch v := txRaw.(type) {
+	case string:
+		txStrs = []interface{}{v}
+	case []interface{}:
+		txStrs = v
+	default:
+		loggerCons.Error("ConstructionMetadata: malformed transaction field")
+		return nil, ErrMalformedValue
+	}
+	txs := make([]*transaction.Transaction, 0, len(txStrs))
+	for _, txStrRaw := range txStrs {
+		txStr, ok := txStrRaw.(string)
+		if !ok {
+			loggerCons.Error("ConstructionMetadata: malformed transaction field")
+			return nil, ErrMalformedValue
+		}
+		rawTx, err := base64.StdEncoding.DecodeString(txStr)
+		if err != nil {
+			loggerCons.Error("ConstructionMetadata: malformed transaction field", "err", err)
+			return nil, ErrMalformedValue
+		}
+		var tx transaction.Transaction
+		if err = cbor.Unmarshal(rawTx, &tx); err != nil {
+			loggerCons.Error("ConstructionMetadata: malformed transaction", "err", err)
+			return nil, ErrMalformedValue
+		}
+		txs = append(txs, &tx)
+	}
+
+	var signer *signature.PublicKey
+	for _, rpk := range publicKeys {
+		var pk signature.PublicKey
+		if err := pk.UnmarshalBinary(rpk.Bytes); err != nil {
+			loggerCons.Error("ConstructionMetadata: malformed public key",
+				"public_key_hex_bytes", hex.EncodeToString(rpk.Bytes),
+				"err", err,
+			)
+			return nil, ErrMalformedValue
+		}
+		if staking.NewAddress(pk).Equal(owner) {
+			signer = &pk
+			break
+		}
+	}
+	if signer == nil {
+		loggerCons.Error("ConstructionMetadata: missing signer's public key",
+			"account_id", owner.String(),
+		)
+		return nil, ErrMalformedValue
+	}
+
+	// The node accounts for the size of the fee itself, so estimate without it.
+	// All transactions in a batch pay the fee of the most expensive one.
+	var gas transaction.Gas
+	for i, tx := range txs {
+		tx.Nonce = nonce + uint64(i)
+		tx.Fee = nil
+		txGas, err := s.oasisClient.EstimateGas(ctx, *signer, tx)
+		if err != nil {
+			loggerCons.Error("ConstructionMetadata: unable to estimate gas",
+				"account_id", owner.String(),
+				"err", err,
+			)
+			return nil, NewDetailedError(ErrUnableToEstimateFee, err)
+		}
+		if txGas > gas {
+			gas = txGas
+		}
+	}
+	minGasPrice, err := s.oasisClient.GetMinGasPrice(ctx)
+	if err != nil {
+		loggerCons.Error("ConstructionMetadata: unable to get minimum gas price", "err", err)
+		return nil, NewDetailedError(ErrUnableToEstimateFee, err)
+	}
+	fee, err := fp.Fee(gas, minGasPrice)
+	if err != nil {
+		loggerCons.Error("ConstructionMetadata: unable to compute fee", "err", err)
+		return nil, NewDetailedError(ErrMalformedValue, err)
+	}
+
+	return fee, nil
+}
+
 // ConstructionSubmit implements the /construction/submit endpoint.
 func (s *constructionAPIService) ConstructionSubmit(
 	ctx context.Context,
@@ -127,7 +322,7 @@ func (s *constructionAPIService) ConstructionSubmit(
 		return nil, terr
 	}
 
-	tx, err := DecodeSignedTransaction(request.SignedTransaction)
+	txs, batch, err := decodeSignedTransactions(request.SignedTransaction)
 	if err != nil {
 		loggerCons.Error("ConstructionSubmit: failed to unmarshal signed transaction",
 			"err", err,
@@ -136,21 +331,36 @@ func (s *constructionAPIService) ConstructionSubmit(
 		return nil, ErrMalformedValue
 	}
 
-	if err := s.oasisClient.SubmitTxNoWait(ctx, tx); err != nil {
-		loggerCons.Error("ConstructionSubmit: SubmitTxNoWait failed", "err", err)
-		if errors.Is(err, consensus.ErrDuplicateTx) {
-			loggerCons.Info("ConstructionSubmit: treating ErrDuplicateTx as success")
-		} else {
-			return nil, NewDetailedError(ErrUnableToSubmitTx, err)
+	// Report user errors as specific errors instead of the node's error.
+	if s.submitPreflight {
+		if terr = s.preflight(ctx, txs); terr != nil {
+			return nil, terr
 		}
 	}
 
-	resp := &types.TransactionIdentifierResponse{
-		TransactionIdentifier: &types.TransactionIdentifier{
-			Hash: tx.Hash().String(),
-		},
+	// Transactions in a batch are submitted in order, since later ones depend
+	// on the nonces of earlier ones.
+	trackHeight := s.trackHeight(ctx)
+	for i, tx := range txs {
+		if err := s.oasisClient.SubmitTxNoWait(ctx, tx); err != nil {
+			loggerCons.Error("ConstructionSubmit: SubmitTxNoWait failed", "err", err)
+			if errors.Is(err, consensus.ErrDuplicateTx) {
+				loggerCons.Info("ConstructionSubmit: treating ErrDuplicateTx as success")
+				s.track(tx, trackHeight)
+				continue
+			}
+			terr := NewDetailedError(ErrUnableToSubmitTx, err)
+			if batch {
+				// Report which transactions of the batch were submitted.
+				terr.Details[TxHashesKey] = txHashes(txs[:i])
+			}
+			return nil, terr
+		}
+		s.track(tx, trackHeight)
 	}
 
+	resp := txIdentifierResponse(txs, batch)
+
 	jr, _ := json.Marshal(resp)
 	loggerCons.Debug("ConstructionSubmit OK", "response", jr)
 
@@ -168,7 +378,7 @@ func (s *constructionAPIService) ConstructionHash(
 		return nil, terr
 	}
 
-	tx, err := DecodeSignedTransaction(request.SignedTransaction)
+	txs, batch, err := decodeSignedTransactions(request.SignedTransaction)
 	if err != nil {
 		loggerCons.Error("ConstructionSubmit: failed to unmarshal signed transaction",
 			"err", err,
@@ -177,11 +387,7 @@ func (s *constructionAPIService) ConstructionHash(
 		return nil, ErrMalformedValue
 	}
 
-	resp := &types.TransactionIdentifierResponse{
-		TransactionIdentifier: &types.TransactionIdentifier{
-			Hash: tx.Hash().String(),
-		},
-	}
+	resp := txIdentifierResponse(txs, batch)
 
 	jr, _ := json.Marshal(resp)
 	loggerCons.Debug("ConstructionHash OK", "response", jr)
@@ -240,7 +446,7 @@ func (s *constructionAPIService) ConstructionCombine(
 	// transaction returned from this method will be sent to the
 	// `/construction/submit` endpoint by the caller.
 
-	ut, err := DecodeUnsignedTransaction(request.UnsignedTransaction)
+	uts, batch, err := decodeUnsignedTransactions(request.UnsignedTransaction)
 	if err != nil {
 		loggerCons.Error("ConstructionCombine: unmarshal unsigned transaction",
 			"unsigned_transaction", request.UnsignedTransaction,
@@ -248,41 +454,71 @@ func (s *constructionAPIService) ConstructionCombine(
 		)
 		return nil, ErrMalformedValue
 	}
-	if len(request.Signatures) != 1 {
-		loggerCons.Error("ConstructionCombine: need exactly one signature",
+	// The signatures of a batch must be in the order of the payloads.
+	if len(request.Signatures) != len(uts) {
+		loggerCons.Error("ConstructionCombine: need exactly one signature per transaction",
 			"len_signatures", len(request.Signatures),
+			"len_transactions", len(uts),
 		)
 		return nil, ErrMalformedValue
 	}
-	sig := request.Signatures[0]
-	var pk signature.PublicKey
-	if err := pk.UnmarshalBinary(sig.PublicKey.Bytes); err != nil {
-		loggerCons.Error("ConstructionCombine: malformed signature public key",
-			"public_key_hex_bytes", hex.EncodeToString(sig.PublicKey.Bytes),
-			"err", err,
-		)
-		return nil, ErrMalformedValue
-	}
-	var rs signature.RawSignature
-	if err := rs.UnmarshalBinary(sig.Bytes); err != nil {
-		loggerCons.Error("ConstructionCombine: malformed signature",
-			"signature_hex_bytes", hex.EncodeToString(sig.Bytes),
-			"err", err,
-		)
-		return nil, ErrMalformedValue
-	}
-	tx := transaction.SignedTransaction{
-		Signed: signature.Signed{
-			Blob: ut.Tx,
-			Signature: signature.Signature{
-				PublicKey: pk,
-				Signature: rs,
+	txs := make([]*transaction.SignedTransaction, 0, len(uts))
+	for i, ut := range uts {
+		sig := request.Signatures[i]
+		var pk signature.PublicKey
+		if err := pk.UnmarshalBinary(sig.PublicKey.Bytes); err != nil {
+			loggerCons.Error("ConstructionCombine: malformed signature public key",
+				"public_key_hex_bytes", hex.EncodeToString(sig.PublicKey.Bytes),
+				"err", err,
+			)
+			return nil, ErrMalformedValue
+		}
+		var rs signature.RawSignature
+		if err := rs.UnmarshalBinary(sig.Bytes); err != nil {
+			loggerCons.Error("ConstructionCombine: malformed signature",
+				"signature_hex_bytes", hex.EncodeToString(sig.Bytes),
+				"err", err,
+			)
+			return nil, ErrMalformedValue
+		}
+		stx := &transaction.SignedTransaction{
+			Signed: signature.Signed{
+				Blob: ut.Tx,
+				Signature: signature.Signature{
+					PublicKey: pk,
+					Signature: rs,
+				},
 			},
-		},
+		}
+
+		// Catch bad signatures here instead of when submitting.
+		if signer := StringFromAddress(staking.NewAddress(pk)); signer != ut.Signer {
+			err = fmt.Errorf("transaction %d: public key of %s doesn't match signer %s", i, signer, ut.Signer)
+			loggerCons.Error("ConstructionCombine: signer mismatch",
+				"err", err,
+			)
+			return nil, NewDetailedError(ErrSignerMismatch, err)
+		}
+		if !stx.Signature.Verify(transaction.SignatureContext, ut.Tx) {
+			err = fmt.Errorf("transaction %d: signature verification failed", i)
+			loggerCons.Error("ConstructionCombine: invalid signature",
+				"public_key_hex_bytes", hex.EncodeToString(sig.PublicKey.Bytes),
+				"signature_hex_bytes", hex.EncodeToString(sig.Bytes),
+				"err", err,
+			)
+			return nil, NewDetailedError(ErrInvalidSignature, err)
+		}
+		txs = append(txs, stx)
 	}
 
+	var txCBOR []byte
+	if batch {
+		txCBOR = cbor.Marshal(txs)
+	} else {
+		txCBOR = cbor.Marshal(txs[0])
+	}
 	resp := &types.ConstructionCombineResponse{
-		SignedTransaction: base64.StdEncoding.EncodeToString(cbor.Marshal(tx)),
+		SignedTransaction: base64.StdEncoding.EncodeToString(txCBOR),
 	}
 
 	jr, _ := json.Marshal(resp)
@@ -307,74 +543,96 @@ func (s *constructionAPIService) ConstructionParse(
 	// before signing (after `/construction/payloads`) and before broadcast
 	// (after `/construction/combine`).
 
-	rawTx, err := base64.StdEncoding.DecodeString(request.Transaction)
-	if err != nil {
-		loggerCons.Error("ConstructionParse: base64 decoding failed",
-			"err", err,
-		)
-		return nil, ErrMalformedValue
-	}
-
-	var tx transaction.Transaction
-	var from string
-	var signers []*types.AccountIdentifier
+	var (
+		txs     []*transaction.Transaction
+		froms   []string
+		batch   bool
+		signers []*types.AccountIdentifier
+	)
 	switch request.Signed {
 	case true:
-		var signedTx transaction.SignedTransaction
-		if err = cbor.Unmarshal(rawTx, &signedTx); err != nil {
+		signedTxs, isBatch, err := decodeSignedTransactions(request.Transaction)
+		if err != nil {
 			loggerCons.Error("ConstructionParse: signed transaction unmarshal",
 				"src", request.Transaction,
 				"err", err,
 			)
 			return nil, ErrMalformedValue
 		}
-		if err = signedTx.Open(&tx); err != nil {
-			loggerCons.Error("ConstructionParse: signed transaction open",
-				"signed_transaction", signedTx,
-				"err", err,
-			)
-			return nil, ErrMalformedValue
+		batch = isBatch
+		for _, signedTx := range signedTxs {
+			var tx transaction.Transaction
+			if err = signedTx.Open(&tx); err != nil {
+				loggerCons.Error("ConstructionParse: signed transaction open",
+					"signed_transaction", signedTx,
+					"err", err,
+				)
+				return nil, ErrMalformedValue
+			}
+			from := StringFromAddress(staking.NewAddress(signedTx.Signature.PublicKey))
+			if len(froms) == 0 || from != froms[len(froms)-1] {
+				signers = append(signers, &types.AccountIdentifier{
+					Address:    from,
+					SubAccount: nil,
+					Metadata:   nil,
+				})
+			}
+			txs = append(txs, &tx)
+			froms = append(froms, from)
 		}
-		from = StringFromAddress(staking.NewAddress(signedTx.Signature.PublicKey))
-		signers = []*types.AccountIdentifier{{
-			Address:    from,
-			SubAccount: nil,
-			Metadata:   nil,
-		}}
 	case false:
-		var unsignedTx UnsignedTransaction
-		if err = cbor.Unmarshal(rawTx, &unsignedTx); err != nil {
+		unsignedTxs, isBatch, err := decodeUnsignedTransactions(request.Transaction)
+		if err != nil {
 			loggerCons.Error("ConstructionParse: unsigned transaction unmarshal",
 				"src", request.Transaction,
 				"err", err,
 			)
 			return nil, ErrMalformedValue
 		}
-		if err = cbor.Unmarshal(unsignedTx.Tx, &tx); err != nil {
-			loggerCons.Error("ConstructionParse: inner unsigned transaction unmarshal",
+		batch = isBatch
+		for _, unsignedTx := range unsignedTxs {
+			var tx transaction.Transaction
+			if err = cbor.Unmarshal(unsignedTx.Tx, &tx); err != nil {
+				loggerCons.Error("ConstructionParse: inner unsigned transaction unmarshal",
+					"err", err,
+				)
+				return nil, ErrMalformedValue
+			}
+			txs = append(txs, &tx)
+			froms = append(froms, unsignedTx.Signer)
+		}
+	}
+
+	ops := []*types.Operation{}
+	for i, tx := range txs {
+		om := newTransactionToOperationMapper(tx, froms[i], nil, []*types.Operation{})
+		om.EmitFeeOps()
+		if err := om.EmitTxOps(); err != nil {
+			loggerCons.Error("ConstructionParse: malformed transaction",
 				"err", err,
 			)
 			return nil, ErrMalformedValue
 		}
-		from = unsignedTx.Signer
-	}
-
-	om := newTransactionToOperationMapper(&tx, from, nil, []*types.Operation{})
-	om.EmitFeeOps()
-	if err := om.EmitTxOps(); err != nil {
-		loggerCons.Error("ConstructionParse: malformed transaction",
-			"err", err,
-		)
-		return nil, ErrMalformedValue
+		if !batch {
+			ops = om.Operations()
+			break
+		}
+		ops = append(ops, withBatchIndex(om.Operations(), i, int64(len(ops)))...)
 	}
 
+	// The nonce of a batch is the nonce of its first transaction.
 	resp := &types.ConstructionParseResponse{
-		Operations:               om.Operations(),
+		Operations:               ops,
 		AccountIdentifierSigners: signers,
 		Metadata: map[string]interface{}{
-			NonceKey: tx.Nonce,
+			NonceKey: txs[0].Nonce,
 		},
 	}
+	if s.oasisClient != nil {
+		if estimates := s.reclaimEscrowEstimates(ctx, ops); len(estimates) > 0 {
+			resp.Metadata[ReclaimEscrowEstimatesKey] = estimates
+		}
+	}
 
 	jr, _ := json.Marshal(resp)
 	loggerCons.Debug("ConstructionParse OK", "response", jr)
@@ -399,8 +657,7 @@ func (s *constructionAPIService) ConstructionPreprocess(
 	// be used by the caller (in a different execution environment) to call
 	// the `/construction/metadata` endpoint.
 
-	om := newOperationToTransactionMapper(request.Operations)
-	signWithAddr, _, err := om.GetTransaction()
+	ct, err := getTransactions(request.Operations, nil)
 	if err != nil {
 		loggerCons.Error("ConstructionPreprocess: bad operations",
 			"err", err,
@@ -408,9 +665,38 @@ func (s *constructionAPIService) ConstructionPreprocess(
 		return nil, NewDetailedError(ErrMalformedValue, err)
 	}
 
+	fp, err := parseFeePolicy(request.Metadata)
+	if err != nil {
+		loggerCons.Error("ConstructionPreprocess: malformed fee policy",
+			"err", err,
+		)
+		return nil, NewDetailedError(ErrMalformedValue, err)
+	}
+
+	// The transaction and the signer's public key are needed to estimate the
+	// fee in `/construction/metadata`.
+	options := fp.Options()
+	options[OptionsIDKey] = ct.signer
+	if ct.batch {
+		txStrs := make([]string, 0, len(ct.txs))
+		for _, tx := range ct.txs {
+			txStrs = append(txStrs, base64.StdEncoding.EncodeToString(cbor.Marshal(tx)))
+		}
+		options[OptionsTxKey] = txStrs
+	} else {
+		options[OptionsTxKey] = base64.StdEncoding.EncodeToString(cbor.Marshal(ct.txs[0]))
+	}
+	if ct.reclaimEscrowAmount != nil {
+		// The amount is converted to shares in `/construction/metadata`.
+		options[OptionsReclaimEscrowAccountKey] = StringFromAddress(*ct.reclaimEscrowAccount)
+		options[ReclaimEscrowAmountKey] = ct.reclaimEscrowAmount.String()
+	}
 	resp := &types.ConstructionPreprocessResponse{
-		Options: map[string]interface{}{
-			OptionsIDKey: signWithAddr,
+		Options: options,
+		RequiredPublicKeys: []*types.AccountIdentifier{
+			{
+				Address: ct.signer,
+			},
 		},
 	}
 
@@ -455,8 +741,7 @@ func (s *constructionAPIService) ConstructionPayloads(
 	}
 	nonce := uint64(nonceF64)
 
-	om := newOperationToTransactionMapper(request.Operations)
-	signWithAddr, tx, err := om.GetTransaction()
+	ct, err := getTransactions(request.Operations, request.Metadata)
 	if err != nil {
 		loggerCons.Error("ConstructionPayloads: bad operations",
 			"err", err,
@@ -464,42 +749,74 @@ func (s *constructionAPIService) ConstructionPayloads(
 		return nil, NewDetailedError(ErrMalformedValue, err)
 	}
 
-	tx.Nonce = nonce
-	ut := UnsignedTransaction{
-		Tx:     cbor.Marshal(tx),
-		Signer: signWithAddr,
-	}
-
-	utCBOR := cbor.Marshal(ut)
+	// Use the suggested fee from `/construction/metadata` (if any) for
+	// transactions without fee operations.
+	mdFee, err := feeFromMetadata(request.Metadata)
 	if err != nil {
-		loggerCons.Error("ConstructionPayloads: marshal unsigned transaction",
-			"unsigned_transaction", ut,
+		loggerCons.Error("ConstructionPayloads: malformed fee metadata",
 			"err", err,
 		)
-		return nil, ErrMalformedValue
+		return nil, NewDetailedError(ErrMalformedValue, err)
 	}
-	txMessage, err := signature.PrepareSignerMessage(transaction.SignatureContext, ut.Tx)
+	fp, err := parseFeePolicy(request.Metadata)
 	if err != nil {
-		loggerCons.Error("ConstructionPayloads: PrepareSignerMessage",
-			"signature_context", transaction.SignatureContext,
-			"tx_hex", hex.EncodeToString(ut.Tx),
+		loggerCons.Error("ConstructionPayloads: malformed fee policy",
 			"err", err,
 		)
-		return nil, ErrMalformedValue
+		return nil, NewDetailedError(ErrMalformedValue, err)
+	}
+
+	// Transactions in a batch use consecutive nonces.
+	uts := make([]*UnsignedTransaction, 0, len(ct.txs))
+	payloads := make([]*types.SigningPayload, 0, len(ct.txs))
+	for i, tx := range ct.txs {
+		tx.Nonce = nonce + uint64(i)
+		if !ct.hasFee[i] && mdFee != nil {
+			tx.Fee = mdFee
+		}
+
+		// Refuse to construct a transaction that pays more than the caller allows.
+		if err = fp.CheckFee(tx.Fee); err != nil {
+			loggerCons.Error("ConstructionPayloads: fee too high",
+				"err", err,
+			)
+			return nil, newFeeExceedsMaximumError(tx.Fee, fp.maxFee)
+		}
+
+		ut := &UnsignedTransaction{
+			Tx:     cbor.Marshal(tx),
+			Signer: ct.signer,
+		}
+		txMessage, err := signature.PrepareSignerMessage(transaction.SignatureContext, ut.Tx)
+		if err != nil {
+			loggerCons.Error("ConstructionPayloads: PrepareSignerMessage",
+				"signature_context", transaction.SignatureContext,
+				"tx_hex", hex.EncodeToString(ut.Tx),
+				"err", err,
+			)
+			return nil, ErrMalformedValue
+		}
+		uts = append(uts, ut)
+		payloads = append(payloads, &types.SigningPayload{
+			AccountIdentifier: &types.AccountIdentifier{
+				Address:    ct.signer,
+				SubAccount: nil,
+				Metadata:   nil,
+			},
+			Bytes:         txMessage,
+			SignatureType: types.Ed25519,
+		})
+	}
+
+	var utCBOR []byte
+	if ct.batch {
+		utCBOR = cbor.Marshal(uts)
+	} else {
+		utCBOR = cbor.Marshal(uts[0])
 	}
 	resp := &types.ConstructionPayloadsResponse{
 		UnsignedTransaction: base64.StdEncoding.EncodeToString(utCBOR),
-		Payloads: []*types.SigningPayload{
-			{
-				AccountIdentifier: &types.AccountIdentifier{
-					Address:    signWithAddr,
-					SubAccount: nil,
-					Metadata:   nil,
-				},
-				Bytes:         txMessage,
-				SignatureType: types.Ed25519,
-			},
-		},
+		Payloads:            payloads,
 	}
 
 	jr, _ := json.Marshal(resp)
@@ -508,6 +825,33 @@ func (s *constructionAPIService) ConstructionPayloads(
 	return resp, nil
 }
 
+// feeFromMetadata returns the fee specified in the given
+// ConstructionPayloadsRequest metadata or nil if there is none.
+func feeFromMetadata(md map[string]interface{}) (*transaction.Fee, error) {
+	amountRaw, ok := md[FeeAmountKey]
+	if !ok {
+		return nil, nil
+	}
+	amountStr, ok := amountRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("malformed fee amount metadata")
+	}
+	var fee transaction.Fee
+	if err := fee.Amount.UnmarshalText([]byte(amountStr)); err != nil {
+		return nil, fmt.Errorf("malformed fee amount metadata (%s): %w", amountStr, err)
+	}
+	gasRaw, ok := md[FeeGasKey]
+	if !ok {
+		return nil, fmt.Errorf("fee gas metadata not specified")
+	}
+	gasF64, ok := gasRaw.(float64)
+	if !ok {
+		return nil, fmt.Errorf("malformed fee gas metadata")
+	}
+	fee.Gas = transaction.Gas(gasF64)
+	return &fee, nil
+}
+
 // DecodeSignedTransaction decodes a signed transaction from a Base64-encoded CBOR blob.
 func DecodeSignedTransaction(raw string) (*transaction.SignedTransaction, error) {
 	rawTx, err := base64.StdEncoding.DecodeString(raw)
@@ -66,6 +66,10 @@ var (
 		Retriable: true,
 	}
 
+	// ErrMustQueryByIndex was returned when blocks were queried by hash.
+	//
+	// Deprecated: Blocks can be queried by hash, so this error is no longer
+	// returned and isn't listed in ErrorList.  Its code is kept reserved.
 	ErrMustQueryByIndex = &types.Error{
 		Code:      9,
 		Message:   "blocks must be queried by index and not hash",
@@ -80,7 +84,7 @@ var (
 
 	ErrMustSpecifySubAccount = &types.Error{
 		Code:      11,
-		Message:   "a valid subaccount must be specified (absent or {\"address\": \"escrow\"})",
+		Message:   "a valid subaccount must be specified (absent, escrow, escrow:active, escrow:debonding or delegated)",
 		Retriable: false,
 	}
 
@@ -138,6 +142,84 @@ var (
 		Retriable: false,
 	}
 
+	ErrBlockHashMismatch = &types.Error{
+		Code:      21,
+		Message:   "block hash doesn't match block index",
+		Retriable: false,
+	}
+
+	ErrBlockNotFound = &types.Error{
+		Code:      22,
+		Message:   "block not found",
+		Retriable: false,
+	}
+
+	ErrUnableToGetBlockEvents = &types.Error{
+		Code:      23,
+		Message:   "unable to get block events",
+		Retriable: true,
+	}
+
+	ErrUnableToEstimateFee = &types.Error{
+		Code:      24,
+		Message:   "unable to estimate fee",
+		Retriable: true,
+	}
+
+	ErrFeeExceedsMaximum = &types.Error{
+		Code:      25,
+		Message:   "fee exceeds maximum fee",
+		Retriable: false,
+	}
+
+	ErrSignerMismatch = &types.Error{
+		Code:      26,
+		Message:   "signature public key doesn't match signer",
+		Retriable: false,
+	}
+
+	ErrInvalidSignature = &types.Error{
+		Code:      27,
+		Message:   "invalid signature",
+		Retriable: false,
+	}
+
+	ErrInvalidNonce = &types.Error{
+		Code:      28,
+		Message:   "invalid nonce",
+		Retriable: false,
+	}
+
+	ErrFeeTooLow = &types.Error{
+		Code:      29,
+		Message:   "fee below minimum gas price",
+		Retriable: false,
+	}
+
+	ErrInsufficientBalance = &types.Error{
+		Code:      30,
+		Message:   "insufficient balance",
+		Retriable: false,
+	}
+
+	ErrSubmitTimeout = &types.Error{
+		Code:      31,
+		Message:   "timed out waiting for transaction inclusion",
+		Retriable: false,
+	}
+
+	ErrTransactionNotTracked = &types.Error{
+		Code:      32,
+		Message:   "transaction not tracked",
+		Retriable: false,
+	}
+
+	ErrUnableToGetTrackedTx = &types.Error{
+		Code:      33,
+		Message:   "unable to get tracked transaction",
+		Retriable: true,
+	}
+
 	ErrorList = []*types.Error{
 		ErrUnableToGetChainID,
 		ErrInvalidBlockchain,
@@ -147,7 +229,6 @@ var (
 		ErrUnableToGetLatestBlk,
 		ErrUnableToGetGenesisBlk,
 		ErrUnableToGetAccount,
-		ErrMustQueryByIndex,
 		ErrInvalidAccountAddress,
 		ErrMustSpecifySubAccount,
 		ErrUnableToGetBlk,
@@ -159,6 +240,19 @@ var (
 		ErrUnableToGetNodeStatus,
 		ErrTransactionNotFound,
 		ErrNotAvailableInOfflineMode,
+		ErrBlockHashMismatch,
+		ErrBlockNotFound,
+		ErrUnableToGetBlockEvents,
+		ErrUnableToEstimateFee,
+		ErrFeeExceedsMaximum,
+		ErrSignerMismatch,
+		ErrInvalidSignature,
+		ErrInvalidNonce,
+		ErrFeeTooLow,
+		ErrInsufficientBalance,
+		ErrSubmitTimeout,
+		ErrTransactionNotTracked,
+		ErrUnableToGetTrackedTx,
 	}
 )
 
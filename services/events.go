@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
+)
+
+const (
+	// DefaultEventsLimit is the number of events returned by the
+	// /events/blocks endpoint if no limit is given.
+	DefaultEventsLimit = 100
+	// MaxEventsLimit is the maximum number of events returned by the
+	// /events/blocks endpoint.
+	MaxEventsLimit = 1000
+)
+
+var loggerEvents = logging.GetLogger("services/events")
+
+type eventsAPIService struct {
+	oasisClient oasis.Client
+	store       *storage.Store
+}
+
+// NewEventsAPIService creates a new instance of an EventsAPIService.
+// The block event log is populated by the transaction indexer.
+func NewEventsAPIService(oasisClient oasis.Client, store *storage.Store) server.EventsAPIServicer {
+	return &eventsAPIService{
+		oasisClient: oasisClient,
+		store:       store,
+	}
+}
+
+// EventsBlocks implements the /events/blocks endpoint.
+func (s *eventsAPIService) EventsBlocks(
+	ctx context.Context,
+	request *types.EventsBlocksRequest,
+) (*types.EventsBlocksResponse, *types.Error) {
+	terr := ValidateNetworkIdentifier(ctx, s.oasisClient, request.NetworkIdentifier)
+	if terr != nil {
+		loggerEvents.Error("EventsBlocks: network validation failed", "err", terr.Message)
+		return nil, terr
+	}
+
+	maxSeq, err := s.store.GetMaxBlockEventSequence()
+	if err != nil {
+		loggerEvents.Error("EventsBlocks: unable to get max sequence", "err", err)
+		return nil, ErrUnableToGetBlockEvents
+	}
+
+	offset := int64(0)
+	if request.Offset != nil {
+		offset = *request.Offset
+	}
+	limit := int64(DefaultEventsLimit)
+	if request.Limit != nil {
+		limit = *request.Limit
+	}
+	if limit > MaxEventsLimit {
+		limit = MaxEventsLimit
+	}
+
+	evs, err := s.store.GetBlockEvents(offset, limit)
+	if err != nil {
+		loggerEvents.Error("EventsBlocks: unable to get block events",
+			"offset", offset,
+			"limit", limit,
+			"err", err,
+		)
+		return nil, ErrUnableToGetBlockEvents
+	}
+
+	events := make([]*types.BlockEvent, 0, len(evs))
+	for _, ev := range evs {
+		events = append(events, &types.BlockEvent{
+			Sequence: ev.Sequence,
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: ev.Height,
+				Hash:  ev.Hash,
+			},
+			Type: types.ADDED,
+		})
+	}
+
+	// The max sequence must not be negative, even if there are no events yet.
+	if maxSeq < 0 {
+		maxSeq = 0
+	}
+
+	resp := &types.EventsBlocksResponse{
+		MaxSequence: maxSeq,
+		Events:      events,
+	}
+
+	jr, _ := json.Marshal(resp)
+	loggerEvents.Debug("EventsBlocks OK", "response", jr)
+
+	return resp, nil
+}
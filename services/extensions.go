@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+)
+
+var loggerExt = logging.GetLogger("services/extensions")
+
+// ExtensionsAPIServicer defines the endpoints of the gateway that aren't part
+// of the Rosetta API.
+type ExtensionsAPIServicer interface {
+	ConstructionSubmitAndWait(
+		context.Context,
+		*ConstructionSubmitAndWaitRequest,
+	) (*ConstructionSubmitAndWaitResponse, *types.Error)
+	ConstructionStatus(
+		context.Context,
+		*ConstructionStatusRequest,
+	) (*ConstructionStatusResponse, *types.Error)
+	MempoolAccount(
+		context.Context,
+		*MempoolAccountRequest,
+	) (*MempoolAccountResponse, *types.Error)
+}
+
+type extensionsAPIService struct {
+	oasisClient  oasis.Client
+	construction *constructionAPIService
+	mempool      *Mempool
+}
+
+// NewExtensionsAPIService creates a new instance of an ExtensionsAPIService.
+// The submission endpoints use the same configuration as the Construction API,
+// except that nonces aren't reserved.
+func NewExtensionsAPIService(oasisClient oasis.Client, cfg *ConstructionConfig) ExtensionsAPIServicer {
+	return &extensionsAPIService{
+		oasisClient: oasisClient,
+		construction: &constructionAPIService{
+			oasisClient:     oasisClient,
+			submitPreflight: cfg.SubmitPreflight,
+			tracker:         cfg.Tracker,
+			mempool:         cfg.Mempool,
+		},
+		mempool: cfg.Mempool,
+	}
+}
+
+// extensionsAPIController routes the extension endpoints to an
+// ExtensionsAPIServicer.
+type extensionsAPIController struct {
+	service ExtensionsAPIServicer
+}
+
+// NewExtensionsAPIController creates a router for the extension endpoints.
+func NewExtensionsAPIController(s ExtensionsAPIServicer) server.Router {
+	return &extensionsAPIController{
+		service: s,
+	}
+}
+
+// Routes returns the routes of the extension endpoints.
+func (c *extensionsAPIController) Routes() server.Routes {
+	return server.Routes{
+		{
+			Name:        "ConstructionSubmitAndWait",
+			Method:      http.MethodPost,
+			Pattern:     "/construction/submit_and_wait",
+			HandlerFunc: c.ConstructionSubmitAndWait,
+		},
+		{
+			Name:        "ConstructionStatus",
+			Method:      http.MethodPost,
+			Pattern:     "/construction/status",
+			HandlerFunc: c.ConstructionStatus,
+		},
+		{
+			Name:        "MempoolAccount",
+			Method:      http.MethodPost,
+			Pattern:     "/mempool/account",
+			HandlerFunc: c.MempoolAccount,
+		},
+	}
+}
+
+// ConstructionSubmitAndWait handles the /construction/submit_and_wait
+// endpoint.
+func (c *extensionsAPIController) ConstructionSubmitAndWait(w http.ResponseWriter, r *http.Request) {
+	request := &ConstructionSubmitAndWaitRequest{}
+	if !decodeExtensionRequest(w, r, request) {
+		return
+	}
+	result, terr := c.service.ConstructionSubmitAndWait(r.Context(), request)
+	encodeExtensionResponse(w, result, terr)
+}
+
+// ConstructionStatus handles the /construction/status endpoint.
+func (c *extensionsAPIController) ConstructionStatus(w http.ResponseWriter, r *http.Request) {
+	request := &ConstructionStatusRequest{}
+	if !decodeExtensionRequest(w, r, request) {
+		return
+	}
+	result, terr := c.service.ConstructionStatus(r.Context(), request)
+	encodeExtensionResponse(w, result, terr)
+}
+
+// MempoolAccount handles the /mempool/account endpoint.
+func (c *extensionsAPIController) MempoolAccount(w http.ResponseWriter, r *http.Request) {
+	request := &MempoolAccountRequest{}
+	if !decodeExtensionRequest(w, r, request) {
+		return
+	}
+	result, terr := c.service.MempoolAccount(r.Context(), request)
+	encodeExtensionResponse(w, result, terr)
+}
+
+// decodeExtensionRequest decodes the JSON request body into the given request.
+// On failure, it writes an error response and returns false.
+func decodeExtensionRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+		return false
+	}
+	return true
+}
+
+// encodeExtensionResponse writes the given result or error as the response,
+// like the Rosetta API controllers do.
+func encodeExtensionResponse(w http.ResponseWriter, result interface{}, terr *types.Error) {
+	if terr != nil {
+		server.EncodeJSONResponse(terr, http.StatusInternalServerError, w)
+		return
+	}
+	server.EncodeJSONResponse(result, http.StatusOK, w)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+)
+
+// MaxFeeKey is the name of the key in the Metadata map inside a
+// ConstructionPreprocessRequest that specifies the maximum fee in base units
+// that the transaction may pay.  It is passed on to the Options map of the
+// ConstructionMetadataRequest and the Metadata map of the
+// ConstructionPayloadsRequest.
+const MaxFeeKey = "max_fee"
+
+// GasPriceKey is the name of the key in the Metadata map inside a
+// ConstructionPreprocessRequest that specifies the gas price in base units to
+// use instead of the node's minimum gas price.  It is passed on to the Options
+// map of the ConstructionMetadataRequest.
+const GasPriceKey = "gas_price"
+
+// FeeMultiplierKey is the name of the key in the Metadata map inside a
+// ConstructionPreprocessRequest that specifies the factor by which the
+// estimated gas is multiplied.  It is passed on to the Options map of the
+// ConstructionMetadataRequest.
+const FeeMultiplierKey = "fee_multiplier"
+
+// feePolicy is the caller's fee policy.
+type feePolicy struct {
+	// maxFee is the maximum fee, if any.
+	maxFee *quantity.Quantity
+	// gasPrice is the gas price, if any.
+	gasPrice *quantity.Quantity
+	// feeMultiplier is the factor by which the estimated gas is multiplied.
+	feeMultiplier float64
+}
+
+// readQuantity reads a quantity given as a string from the given map.
+// It returns nil if the key is not present.
+func readQuantity(m map[string]interface{}, key string) (*quantity.Quantity, error) {
+	raw, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	str, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("malformed %s", key)
+	}
+	var q quantity.Quantity
+	if err := q.UnmarshalText([]byte(str)); err != nil {
+		return nil, fmt.Errorf("malformed %s (%s): %w", key, str, err)
+	}
+	return &q, nil
+}
+
+// parseFeePolicy parses the fee policy from the given map.
+func parseFeePolicy(m map[string]interface{}) (*feePolicy, error) {
+	var (
+		fp  = feePolicy{feeMultiplier: 1}
+		err error
+	)
+	if fp.maxFee, err = readQuantity(m, MaxFeeKey); err != nil {
+		return nil, err
+	}
+	if fp.gasPrice, err = readQuantity(m, GasPriceKey); err != nil {
+		return nil, err
+	}
+	if raw, ok := m[FeeMultiplierKey]; ok {
+		if fp.feeMultiplier, ok = raw.(float64); !ok || fp.feeMultiplier <= 0 {
+			return nil, fmt.Errorf("malformed %s", FeeMultiplierKey)
+		}
+	}
+	return &fp, nil
+}
+
+// Options returns the fee policy in the form of options to be passed on.
+func (fp *feePolicy) Options() map[string]interface{} {
+	opts := make(map[string]interface{})
+	if fp.maxFee != nil {
+		opts[MaxFeeKey] = fp.maxFee.String()
+	}
+	if fp.gasPrice != nil {
+		opts[GasPriceKey] = fp.gasPrice.String()
+	}
+	if fp.feeMultiplier != 1 {
+		opts[FeeMultiplierKey] = fp.feeMultiplier
+	}
+	return opts
+}
+
+// Fee computes the fee for the given estimated gas and the node's minimum gas
+// price according to the policy.
+func (fp *feePolicy) Fee(gas transaction.Gas, minGasPrice *quantity.Quantity) (*transaction.Fee, error) {
+	gasF64 := math.Ceil(float64(gas) * fp.feeMultiplier)
+	if gasF64 >= math.MaxUint64 {
+		return nil, fmt.Errorf("gas overflow")
+	}
+	fee := transaction.Fee{
+		Gas: transaction.Gas(gasF64),
+	}
+
+	// A gas price below the minimum would make the transaction invalid.
+	gasPrice := minGasPrice
+	if fp.gasPrice != nil && fp.gasPrice.Cmp(minGasPrice) > 0 {
+		gasPrice = fp.gasPrice
+	}
+	fee.Amount = *gasPrice.Clone()
+	if err := fee.Amount.Mul(quantity.NewFromUint64(uint64(fee.Gas))); err != nil {
+		return nil, err
+	}
+	return &fee, nil
+}
+
+// CheckFee returns an error if the given fee exceeds the maximum fee.
+func (fp *feePolicy) CheckFee(fee *transaction.Fee) error {
+	if fp.maxFee == nil || fee == nil || fee.Amount.Cmp(fp.maxFee) <= 0 {
+		return nil
+	}
+	return fmt.Errorf("fee %s exceeds maximum fee %s", fee.Amount.String(), fp.maxFee.String())
+}
+
+// newFeeExceedsMaximumError returns an ErrFeeExceedsMaximum error with the
+// offending fee and the maximum fee in its details.
+func newFeeExceedsMaximumError(fee *transaction.Fee, maxFee *quantity.Quantity) *types.Error {
+	terr := *ErrFeeExceedsMaximum
+	terr.Details = map[string]interface{}{
+		FeeAmountKey: fee.Amount.String(),
+		FeeGasKey:    fee.Gas,
+		MaxFeeKey:    maxFee.String(),
+	}
+	return &terr
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
+)
+
+// IndexerEnvVar is the name of the environment variable that enables the
+// embedded transaction indexer (and the endpoints that depend on it) when set
+// to a non-empty value.
+const IndexerEnvVar = "OASIS_ROSETTA_GATEWAY_INDEXER"
+
+// indexerPollInterval is the time the indexer waits for new blocks after it
+// has caught up with the chain or after an error.
+const indexerPollInterval = 1 * time.Second
+
+var loggerIdx = logging.GetLogger("services/indexer")
+
+// Indexer follows the chain and records transactions in the local store so
+// that they can be searched for.  It also records a block event for each
+// indexed block.
+type Indexer struct {
+	oasisClient oasis.Client
+	store       *storage.Store
+
+	nextHeight   int64
+	latestHeight int64
+}
+
+// NewIndexer creates a new transaction indexer.
+func NewIndexer(oasisClient oasis.Client, store *storage.Store) *Indexer {
+	return &Indexer{
+		oasisClient: oasisClient,
+		store:       store,
+	}
+}
+
+// Run indexes blocks until the given context is canceled.
+func (ix *Indexer) Run(ctx context.Context) {
+	for {
+		caughtUp, err := ix.indexNext(ctx)
+		if err != nil {
+			loggerIdx.Error("failed to index block",
+				"height", ix.nextHeight,
+				"err", err,
+			)
+		}
+		if err == nil && !caughtUp {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(indexerPollInterval):
+		}
+	}
+}
+
+// indexNext indexes the next block.  It returns true if there was no block to
+// index.
+func (ix *Indexer) indexNext(ctx context.Context) (bool, error) {
+	if ix.nextHeight == 0 || ix.nextHeight > ix.latestHeight {
+		status, err := ix.oasisClient.GetStatus(ctx)
+		if err != nil {
+			return true, fmt.Errorf("unable to get node status: %w", err)
+		}
+		ix.latestHeight = status.Consensus.LatestHeight
+
+		if ix.nextHeight == 0 {
+			height, ok, err := ix.store.GetIndexedHeight()
+			if err != nil {
+				return true, fmt.Errorf("unable to get indexed height: %w", err)
+			}
+			switch ok {
+			case true:
+				ix.nextHeight = height + 1
+			case false:
+				ix.nextHeight = status.Consensus.LastRetainedHeight
+				if ix.nextHeight < status.Consensus.GenesisHeight {
+					ix.nextHeight = status.Consensus.GenesisHeight
+				}
+				loggerIdx.Info("starting to index", "height", ix.nextHeight)
+			}
+		}
+		if ix.nextHeight > ix.latestHeight {
+			return true, nil
+		}
+	}
+
+	if err := ix.indexBlock(ctx, ix.nextHeight); err != nil {
+		return true, err
+	}
+	ix.nextHeight++
+	return false, nil
+}
+
+// indexBlock indexes all transactions in the block at the given height.
+func (ix *Indexer) indexBlock(ctx context.Context, height int64) error {
+	blk, err := ix.oasisClient.GetBlock(ctx, height)
+	if err != nil {
+		return fmt.Errorf("unable to get block: %w", err)
+	}
+	var blkHash hash.Hash
+	if err = blkHash.UnmarshalHex(blk.Hash); err != nil {
+		return fmt.Errorf("malformed block hash: %w", err)
+	}
+
+	txsWithRes, err := ix.oasisClient.GetTransactionsWithResults(ctx, height)
+	if err != nil {
+		return fmt.Errorf("unable to get transactions: %w", err)
+	}
+	evts, err := ix.oasisClient.GetStakingEvents(ctx, height)
+	if err != nil {
+		return fmt.Errorf("unable to get staking events: %w", err)
+	}
+
+	td := newTransactionsDecoder()
+	var itxs []*storage.IndexedTransaction
+	for i, rawTx := range txsWithRes.Transactions {
+		if err = td.DecodeTx(rawTx, txsWithRes.Results[i]); err != nil {
+			loggerIdx.Warn("malformed transaction",
+				"height", height,
+				"index", i,
+				"err", err,
+			)
+			continue
+		}
+
+		// The signature has already been verified by DecodeTx.
+		var sigTx transaction.SignedTransaction
+		var tx transaction.Transaction
+		if err = cbor.Unmarshal(rawTx, &sigTx); err != nil {
+			return fmt.Errorf("malformed transaction: %w", err)
+		}
+		if err = cbor.Unmarshal(sigTx.Blob, &tx); err != nil {
+			return fmt.Errorf("malformed transaction: %w", err)
+		}
+		signer := staking.NewAddress(sigTx.Signature.PublicKey)
+
+		rosettaTx := td.Transaction(sigTx.Hash())
+		itxs = append(itxs, &storage.IndexedTransaction{
+			Height:      blk.Height,
+			Index:       uint32(i),
+			BlockHash:   blk.Hash,
+			Method:      string(tx.Method),
+			Signer:      StringFromAddress(signer),
+			Accounts:    affectedAccounts(&signer, rosettaTx.Operations),
+			Transaction: rosettaTx,
+		})
+	}
+
+	if err = td.DecodeBlock(blkHash, evts, escrowPoolAt(ctx, ix.oasisClient, blk.Height)); err != nil {
+		return fmt.Errorf("unable to decode block events: %w", err)
+	}
+	if rosettaTx := td.Transaction(blkHash); rosettaTx != nil {
+		itxs = append(itxs, &storage.IndexedTransaction{
+			Height:      blk.Height,
+			Index:       uint32(len(txsWithRes.Transactions)),
+			BlockHash:   blk.Hash,
+			Accounts:    affectedAccounts(nil, rosettaTx.Operations),
+			Transaction: rosettaTx,
+		})
+	}
+
+	if err = ix.store.PutIndexedBlock(blk.Height, blkHash, itxs); err != nil {
+		return fmt.Errorf("unable to store transactions: %w", err)
+	}
+
+	loggerIdx.Debug("indexed block",
+		"height", blk.Height,
+		"num_txs", len(itxs),
+	)
+	return nil
+}
+
+// affectedAccounts returns the signer (if any) and all distinct accounts
+// touched by the given operations.
+func affectedAccounts(signer *staking.Address, ops []*types.Operation) []staking.Address {
+	seen := make(map[staking.Address]bool)
+	var accounts []staking.Address
+	add := func(addr staking.Address) {
+		if !seen[addr] {
+			seen[addr] = true
+			accounts = append(accounts, addr)
+		}
+	}
+
+	if signer != nil {
+		add(*signer)
+	}
+	for _, op := range ops {
+		var addr staking.Address
+		if err := addr.UnmarshalText([]byte(op.Account.Address)); err != nil {
+			continue
+		}
+		add(addr)
+	}
+	return accounts
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
 	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
 
 	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
 )
@@ -17,12 +18,14 @@ var loggerMempool = logging.GetLogger("services/mempool")
 
 type mempoolAPIService struct {
 	oasisClient oasis.Client
+	mempool     *Mempool
 }
 
 // NewMempoolAPIService creates a new instance of a NetworkAPIService.
-func NewMempoolAPIService(oasisClient oasis.Client) server.MempoolAPIServicer {
+func NewMempoolAPIService(oasisClient oasis.Client, mempool *Mempool) server.MempoolAPIServicer {
 	return &mempoolAPIService{
 		oasisClient: oasisClient,
+		mempool:     mempool,
 	}
 }
 
@@ -37,16 +40,16 @@ func (s *mempoolAPIService) Mempool(
 		return nil, terr
 	}
 
-	txs, err := s.oasisClient.GetUnconfirmedTransactions(ctx)
+	snap, err := s.mempool.snapshot(ctx)
 	if err != nil {
 		loggerMempool.Error("Mempool: unable to get unconfirmed transactions", "err", err)
 		return nil, ErrUnableToGetTxns
 	}
 
-	tids := make([]*types.TransactionIdentifier, 0, len(txs))
-	for _, tx := range txs {
+	tids := make([]*types.TransactionIdentifier, 0, len(snap.entries))
+	for _, entry := range snap.entries {
 		tids = append(tids, &types.TransactionIdentifier{
-			Hash: hash.NewFromBytes(tx).String(),
+			Hash: entry.hash.String(),
 		})
 	}
 
@@ -71,31 +74,34 @@ func (s *mempoolAPIService) MempoolTransaction(
 		return nil, terr
 	}
 
-	txs, err := s.oasisClient.GetUnconfirmedTransactions(ctx)
+	var txHash hash.Hash
+	if err := txHash.UnmarshalHex(request.TransactionIdentifier.Hash); err != nil {
+		loggerMempool.Error("MempoolTransaction: malformed transaction hash",
+			"hash", request.TransactionIdentifier.Hash,
+			"err", err,
+		)
+		return nil, ErrTransactionNotFound
+	}
+
+	snap, err := s.mempool.snapshot(ctx)
 	if err != nil {
 		loggerMempool.Error("MempoolTransaction: unable to get unconfirmed transactions", "err", err)
 		return nil, ErrUnableToGetTxns
 	}
 
-	var foundTx []byte
-	for _, tx := range txs {
-		if hash.NewFromBytes(tx).String() == request.TransactionIdentifier.Hash {
-			foundTx = tx
-			break
-		}
-	}
-	if foundTx == nil {
+	entry, ok := snap.byHash[txHash]
+	if !ok {
 		return nil, ErrTransactionNotFound
 	}
-
-	td := newTransactionsDecoder()
-	if err = td.DecodeTx(foundTx, nil); err != nil {
-		loggerMempool.Error("MempoolTransaction: unable to decode unconfirmed transaction", "err", err)
+	if entry.tx == nil {
+		loggerMempool.Error("MempoolTransaction: unable to decode unconfirmed transaction",
+			"tx_hash", txHash.String(),
+		)
 		return nil, ErrUnableToGetTxns
 	}
 
 	resp := &types.MempoolTransactionResponse{
-		Transaction: td.Transactions()[0],
+		Transaction: entry.tx,
 	}
 
 	jr, _ := json.Marshal(resp)
@@ -103,3 +109,85 @@ func (s *mempoolAPIService) MempoolTransaction(
 
 	return resp, nil
 }
+
+const (
+	// MempoolDirectionOutgoing selects the transactions signed by the account
+	// in a MempoolAccountRequest.
+	MempoolDirectionOutgoing = "outgoing"
+	// MempoolDirectionIncoming selects the transactions that affect the
+	// account, but aren't signed by it, in a MempoolAccountRequest.
+	MempoolDirectionIncoming = "incoming"
+)
+
+// MempoolAccountRequest is the request of the /mempool/account endpoint.
+type MempoolAccountRequest struct {
+	NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	AccountIdentifier *types.AccountIdentifier `json:"account_identifier"`
+	// Direction is MempoolDirectionOutgoing or MempoolDirectionIncoming to
+	// select only those transactions.  All transactions affecting the
+	// account are returned if it is empty.
+	Direction string `json:"direction,omitempty"`
+}
+
+// MempoolAccountResponse is the response of the /mempool/account endpoint.
+type MempoolAccountResponse struct {
+	Transactions []*types.Transaction `json:"transactions"`
+}
+
+// MempoolAccount implements the /mempool/account endpoint.
+func (s *extensionsAPIService) MempoolAccount(
+	ctx context.Context,
+	request *MempoolAccountRequest,
+) (*MempoolAccountResponse, *types.Error) {
+	terr := ValidateNetworkIdentifier(ctx, s.oasisClient, request.NetworkIdentifier)
+	if terr != nil {
+		loggerMempool.Error("MempoolAccount: network validation failed", "err", terr.Message)
+		return nil, terr
+	}
+
+	if request.AccountIdentifier == nil || request.AccountIdentifier.Address == "" {
+		loggerMempool.Error("MempoolAccount: invalid account address (empty)")
+		return nil, ErrInvalidAccountAddress
+	}
+	var owner staking.Address
+	if err := owner.UnmarshalText([]byte(request.AccountIdentifier.Address)); err != nil {
+		loggerMempool.Error("MempoolAccount: invalid account address", "err", err)
+		return nil, ErrInvalidAccountAddress
+	}
+	addr := StringFromAddress(owner)
+
+	snap, err := s.mempool.snapshot(ctx)
+	if err != nil {
+		loggerMempool.Error("MempoolAccount: unable to get unconfirmed transactions", "err", err)
+		return nil, ErrUnableToGetTxns
+	}
+
+	var entries []*mempoolEntry
+	switch request.Direction {
+	case "":
+		entries = snap.byAccount[addr]
+	case MempoolDirectionOutgoing:
+		entries = snap.bySigner[addr]
+	case MempoolDirectionIncoming:
+		for _, entry := range snap.byAccount[addr] {
+			if entry.signer != addr {
+				entries = append(entries, entry)
+			}
+		}
+	default:
+		loggerMempool.Error("MempoolAccount: invalid direction", "direction", request.Direction)
+		return nil, ErrMalformedValue
+	}
+
+	resp := &MempoolAccountResponse{
+		Transactions: make([]*types.Transaction, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		resp.Transactions = append(resp.Transactions, entry.tx)
+	}
+
+	jr, _ := json.Marshal(resp)
+	loggerMempool.Debug("MempoolAccount OK", "response", jr)
+
+	return resp, nil
+}
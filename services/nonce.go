@@ -0,0 +1,99 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+)
+
+// NonceReservationTTLEnvVar is the name of the environment variable that
+// specifies for how many seconds a nonce handed out by /construction/metadata
+// stays reserved.  While reserved, the nonce isn't handed out again, and
+// neither are the nonces of the signer's transactions in the mempool.
+// If unset or zero, /construction/metadata returns the next nonce at the
+// latest height.
+const NonceReservationTTLEnvVar = "OASIS_ROSETTA_GATEWAY_NONCE_RESERVATION_TTL"
+
+// nonceReservations keeps track of the nonces handed out by
+// /construction/metadata, so that concurrent constructions for the same
+// account get different nonces.
+type nonceReservations struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	// expiries maps accounts to their reserved nonces and the time at which
+	// the reservations expire.
+	expiries map[staking.Address]map[uint64]time.Time
+}
+
+func newNonceReservations(ttl time.Duration) *nonceReservations {
+	return &nonceReservations{
+		ttl:      ttl,
+		expiries: make(map[staking.Address]map[uint64]time.Time),
+	}
+}
+
+// reserve reserves count consecutive nonces of the given account and returns
+// the first one.  These are the lowest nonces that are at least the given next
+// nonce and that are neither pending nor reserved.
+func (r *nonceReservations) reserve(owner staking.Address, next uint64, pending map[uint64]bool, count int) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for addr, expiries := range r.expiries {
+		for nonce, expiry := range expiries {
+			if !now.Before(expiry) || (addr == owner && (nonce < next || pending[nonce])) {
+				delete(expiries, nonce)
+			}
+		}
+		if len(expiries) == 0 {
+			delete(r.expiries, addr)
+		}
+	}
+
+	expiries := r.expiries[owner]
+	first := next
+	for i := 0; i < count; i++ {
+		nonce := first + uint64(i)
+		if _, reserved := expiries[nonce]; reserved || pending[nonce] {
+			// Start over after the taken nonce.
+			first = nonce + 1
+			i = -1
+		}
+	}
+
+	if expiries == nil {
+		expiries = make(map[uint64]time.Time)
+		r.expiries[owner] = expiries
+	}
+	for i := 0; i < count; i++ {
+		expiries[first+uint64(i)] = now.Add(r.ttl)
+	}
+	return first
+}
+
+// release releases count consecutive reserved nonces of the given account,
+// starting with first, so that they can be handed out again.
+func (r *nonceReservations) release(owner staking.Address, first uint64, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expiries := r.expiries[owner]
+	for i := 0; i < count; i++ {
+		delete(expiries, first+uint64(i))
+	}
+	if len(expiries) == 0 {
+		delete(r.expiries, owner)
+	}
+}
+
+// txCount returns the number of transactions in the given transaction option,
+// which is a single transaction or a list of them for a batch.
+func txCount(txRaw interface{}) int {
+	if txs, ok := txRaw.([]interface{}); ok && len(txs) > 0 {
+		return len(txs)
+	}
+	return 1
+}
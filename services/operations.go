@@ -1,8 +1,13 @@
 package services
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
+	"reflect"
+	"strconv"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/oasisprotocol/oasis-core/go/common/cbor"
@@ -10,7 +15,16 @@ import (
 	"github.com/oasisprotocol/oasis-core/go/common/quantity"
 	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
 	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction/results"
+	governance "github.com/oasisprotocol/oasis-core/go/governance/api"
 	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	// Register the body types of all consensus methods.
+	_ "github.com/oasisprotocol/oasis-core/go/beacon/api"
+	_ "github.com/oasisprotocol/oasis-core/go/keymanager/churp"
+	_ "github.com/oasisprotocol/oasis-core/go/keymanager/secrets"
+	_ "github.com/oasisprotocol/oasis-core/go/registry/api"
+	_ "github.com/oasisprotocol/oasis-core/go/roothash/api"
+	_ "github.com/oasisprotocol/oasis-core/go/vault/api"
 )
 
 // FeeGasKey is the name of the key in the Metadata map inside a fee
@@ -22,6 +36,131 @@ const FeeGasKey = "fee_gas"
 // reclaim escrow operation that specifies the number of shares to reclaim.
 const ReclaimEscrowSharesKey = "reclaim_escrow_shares"
 
+// ReclaimEscrowAmountKey is the name of the key in the Metadata map inside a
+// reclaim escrow operation that specifies the amount in base units to reclaim
+// instead of the number of shares.  The amount is converted to shares by
+// /construction/metadata.
+const ReclaimEscrowAmountKey = "reclaim_escrow_amount"
+
+// ReclaimEscrowEstimatesKey is the name of the key in the Metadata map inside
+// a ConstructionParseResponse that specifies the estimated amounts in base
+// units that the shares reclaimed by the reclaim escrow operations are worth at
+// the latest height, as a list of ReclaimEscrowEstimate.
+const ReclaimEscrowEstimatesKey = "reclaim_escrow_estimates"
+
+// AllowanceNegativeKey is the name of the key in the Metadata map inside an
+// allow operation that specifies whether the allowance is decreased (true) or
+// increased (false).
+const AllowanceNegativeKey = "negative"
+
+// AllowanceAmountChangeKey is the name of the key in the Metadata map inside
+// an allow operation that specifies the amount by which the allowance changes.
+const AllowanceAmountChangeKey = "amount_change"
+
+// AllowanceKey is the name of the key in the Metadata map inside an allow
+// operation emitted for an allowance change event that specifies the new
+// allowance.
+const AllowanceKey = "allowance"
+
+// DebondingOwnerKey is the name of the key in the Metadata map inside a
+// debonding start operation that specifies the owner of the debonding stake.
+const DebondingOwnerKey = "owner"
+
+// ActiveSharesChangeKey is the name of the key in the Metadata map inside a
+// debonding start operation that specifies the number of active shares that
+// started debonding.
+const ActiveSharesChangeKey = "active_shares"
+
+// DebondingSharesChangeKey is the name of the key in the Metadata map inside
+// a debonding start operation that specifies the number of debonding shares
+// created.
+const DebondingSharesChangeKey = "debonding_shares"
+
+// DebondEndEpochKey is the name of the key in the Metadata map inside a
+// debonding start operation that specifies the epoch at which the stake
+// stops debonding.
+const DebondEndEpochKey = "debond_end_epoch"
+
+// RewardTypeKey is the name of the key in the Metadata map inside a reward
+// operation that specifies the type of the reward (RewardTypeStaking,
+// RewardTypeCommission or RewardTypeGeneral).
+const RewardTypeKey = "reward_type"
+
+// RewardEscrowTotalSharesKey is the name of the key in the Metadata map inside
+// a staking reward operation that specifies the total number of shares in the
+// escrow account's active pool.  The shares don't change with the reward.
+const RewardEscrowTotalSharesKey = "total_shares"
+
+// RewardEscrowActiveBalanceKey is the name of the key in the Metadata map
+// inside a staking reward operation that specifies the balance of the escrow
+// account's active pool before the reward, in base units.
+const RewardEscrowActiveBalanceKey = "active_balance"
+
+const (
+	// RewardTypeStaking is the reward type of staking rewards, which are added
+	// to an escrow account's active pool without issuing new shares, so they
+	// are distributed among the escrow account's delegators pro-rata to their
+	// shares.
+	RewardTypeStaking = "staking"
+	// RewardTypeCommission is the reward type of commission paid to the owner
+	// of an escrow account, which is escrowed right away.
+	RewardTypeCommission = "commission"
+	// RewardTypeGeneral is the reward type of other payments from the common
+	// pool to an account's general balance (e.g. runtime rewards).
+	RewardTypeGeneral = "general"
+)
+
+// SlashedEscrowKey is the name of the key in the Metadata map inside a slash
+// operation that specifies the address of the slashed escrow account.
+const SlashedEscrowKey = "escrow"
+
+// SlashedDebondingAmountKey is the name of the key in the Metadata map inside
+// a slash operation that specifies the part of the slashed amount that was
+// taken from the escrow account's debonding pool.
+const SlashedDebondingAmountKey = "debonding_amount"
+
+// TxMethodKey is the name of the key in the Metadata map inside a transaction
+// that specifies the consensus method called by the transaction.
+const TxMethodKey = "method"
+
+// TxNonceKey is the name of the key in the Metadata map inside a transaction
+// that specifies the transaction's nonce.
+const TxNonceKey = "nonce"
+
+// TxGasLimitKey is the name of the key in the Metadata map inside a
+// transaction that specifies the gas limit of the transaction's fee.
+const TxGasLimitKey = "gas_limit"
+
+// TxSignerPublicKeyKey is the name of the key in the Metadata map inside a
+// transaction that specifies the signer's public key.
+const TxSignerPublicKeyKey = "signer_public_key"
+
+// TxErrorKey is the name of the key in the Metadata map inside a failed
+// transaction that specifies the error.  The value is an object with keys
+// ModuleKey, CodeKey and MsgKey.
+const TxErrorKey = "error"
+
+// CallMethodKey is the name of the key in the Metadata map inside a call
+// operation that specifies the called consensus method.
+const CallMethodKey = "method"
+
+// CallBodyKey is the name of the key in the Metadata map inside a call
+// operation that specifies the JSON rendering of the call's body.
+const CallBodyKey = "body"
+
+// CallBodyCBORKey is the name of the key in the Metadata map inside a call
+// operation that specifies the Base64 encoded CBOR body of the call.  It is
+// used instead of CallBodyKey if the body can't be decoded.
+const CallBodyCBORKey = "body_cbor"
+
+// ProposalIDKey is the name of the key in the Metadata map inside a cast vote
+// operation that specifies the identifier of the proposal voted on.
+const ProposalIDKey = "proposal_id"
+
+// VoteKey is the name of the key in the Metadata map inside a cast vote
+// operation that specifies the vote ("yes", "no" or "abstain").
+const VoteKey = "vote"
+
 // DefaultGas is the default gas limit used in creating a transaction.
 const DefaultGas transaction.Gas = 10000
 
@@ -32,6 +171,24 @@ const (
 	OpBurn = "Burn"
 	// OpReclaimEscrow is the Burn operation.
 	OpReclaimEscrow = "ReclaimEscrow"
+	// OpAllow is the Allow operation.
+	OpAllow = "Allow"
+	// OpWithdraw is the Withdraw operation.
+	OpWithdraw = "Withdraw"
+	// OpDebondingStart is the DebondingStart operation.
+	OpDebondingStart = "DebondingStart"
+	// OpReward is the Reward operation.
+	OpReward = "Reward"
+	// OpSlash is the Slash operation.
+	OpSlash = "Slash"
+	// OpCall is the Call operation.
+	OpCall = "Call"
+	// OpSubmitProposal is the SubmitProposal operation.
+	OpSubmitProposal = "SubmitProposal"
+	// OpCastVote is the CastVote operation.
+	OpCastVote = "CastVote"
+	// OpDepositRefund is the DepositRefund operation.
+	OpDepositRefund = "DepositRefund"
 )
 
 // SupportedOperationTypes is a list of the supported operations.
@@ -39,6 +196,29 @@ var SupportedOperationTypes = []string{
 	OpTransfer,
 	OpBurn,
 	OpReclaimEscrow,
+	OpAllow,
+	OpWithdraw,
+	OpDebondingStart,
+	OpReward,
+	OpSlash,
+	OpCall,
+	OpSubmitProposal,
+	OpCastVote,
+	OpDepositRefund,
+}
+
+// stakingTxMethods are the methods whose effects are represented by staking
+// operations (e.g., the proposal deposit of governance.SubmitProposal).  All
+// other methods are represented by operations emitted by EmitTxOps even if
+// they are successful (e.g., a Call operation).
+var stakingTxMethods = map[transaction.MethodName]bool{
+	staking.MethodTransfer:          true,
+	staking.MethodBurn:              true,
+	staking.MethodAddEscrow:         true,
+	staking.MethodReclaimEscrow:     true,
+	staking.MethodAllow:             true,
+	staking.MethodWithdraw:          true,
+	governance.MethodSubmitProposal: true,
 }
 
 const (
@@ -48,9 +228,17 @@ const (
 	OpStatusFailed = "Failed"
 )
 
+// escrowPoolFunc returns the active pool of the given escrow account after the
+// block that is being decoded.
+type escrowPoolFunc func(escrow staking.Address) (*staking.SharePool, error)
+
 type transactionsDecoder struct {
 	txs   []*types.Transaction
 	index map[hash.Hash]*types.Transaction
+
+	// rewardPools are the escrow accounts' active pools before each staking
+	// reward in the block.
+	rewardPools map[*staking.Event]*staking.SharePool
 }
 
 func (d *transactionsDecoder) DecodeTx(rawTx []byte, result *results.Result) error {
@@ -65,6 +253,7 @@ func (d *transactionsDecoder) DecodeTx(rawTx []byte, result *results.Result) err
 
 	txHash := sigTx.Hash()
 	rosettaTx := d.getOrCreateTx(txHash)
+	rosettaTx.Metadata = txMetadata(&sigTx, &tx, result)
 
 	// Decode events emitted by the transaction.
 	if result != nil {
@@ -87,50 +276,194 @@ func (d *transactionsDecoder) DecodeTx(rawTx []byte, result *results.Result) err
 	// Case 3:
 	// * Result is not provided because the transaction has not yet been executed. In this case
 	//   nothing has been emitted yet so we need to generate OK operations.
-	if result == nil || !result.IsSuccess() {
-		txSignerAddress := StringFromAddress(staking.NewAddress(sigTx.Signature.PublicKey))
-		o2t := newOperationToTransactionMapper(rosettaTx.Operations)
-
-		var status string
-		switch {
-		case result == nil:
-			status = OpStatusOK
-		default:
-			status = OpStatusFailed
-		}
-		t2o := newTransactionToOperationMapper(&tx, txSignerAddress, &status, rosettaTx.Operations)
+	//
+	// Case 4:
+	// * The transaction succeeded, but the effects of its method are not (fully) represented by
+	//   staking events (see stakingTxMethods), so we need to generate OK operations.
+	//
+	// Transactions of non-staking methods (e.g. registry methods) are represented by a Call
+	// operation, which is Failed in cases 1 and 2.
+	var status string
+	switch {
+	case result == nil:
+		status = OpStatusOK
+	case !result.IsSuccess():
+		status = OpStatusFailed
+	case !stakingTxMethods[tx.Method]:
+		status = OpStatusOK
+	default:
+		return nil
+	}
+	txSignerAddress := StringFromAddress(staking.NewAddress(sigTx.Signature.PublicKey))
+	t2o := newTransactionToOperationMapper(&tx, txSignerAddress, &status, rosettaTx.Operations)
 
-		// If no fee operations were emitted, emit some now.
-		if !o2t.HasFee() {
+	// If no fee operations were emitted for a failed or pending transaction,
+	// emit some now.
+	if result == nil || !result.IsSuccess() {
+		if o2t := newOperationToTransactionMapper(rosettaTx.Operations); !o2t.HasFee() {
 			t2o.EmitFeeOps()
 		}
-		if err := t2o.EmitTxOps(); err != nil {
-			return fmt.Errorf("bad transaction: %w", err)
+	}
+	if err := t2o.EmitTxOps(); err != nil {
+		return fmt.Errorf("bad transaction: %w", err)
+	}
+	rosettaTx.Operations = t2o.Operations()
+	return nil
+}
+
+// txMetadata returns the metadata of the given transaction.
+func txMetadata(
+	sigTx *transaction.SignedTransaction,
+	tx *transaction.Transaction,
+	result *results.Result,
+) map[string]interface{} {
+	md := map[string]interface{}{
+		TxMethodKey:          string(tx.Method),
+		TxNonceKey:           tx.Nonce,
+		TxSignerPublicKeyKey: sigTx.Signature.PublicKey.String(),
+	}
+	if tx.Fee != nil {
+		md[TxGasLimitKey] = uint64(tx.Fee.Gas)
+	}
+	if result != nil && !result.IsSuccess() {
+		md[TxErrorKey] = resultError(result)
+	}
+	return md
+}
+
+// resultError returns the error of the given failed transaction result as a
+// map with the keys ModuleKey, CodeKey and MsgKey.
+func resultError(result *results.Result) map[string]interface{} {
+	return map[string]interface{}{
+		ModuleKey: result.Error.Module,
+		CodeKey:   result.Error.Code,
+		MsgKey:    result.Error.Message,
+	}
+}
+
+// DecodeBlock decodes the block-level events among the given events of the
+// block.  If escrowPool is not nil, it is used to report the escrow accounts'
+// active pools before staking rewards.
+func (d *transactionsDecoder) DecodeBlock(blkHash hash.Hash, events []*staking.Event, escrowPool escrowPoolFunc) error {
+	if escrowPool != nil {
+		pools, err := rewardPools(events, escrowPool)
+		if err != nil {
+			return err
 		}
+		d.rewardPools = pools
+	}
 
-		rosettaTx.Operations = t2o.Operations()
+	// We put all block-level events under an empty "transaction". All other events are skipped
+	// as they have already been processed during DecodeTx.
+	var blkEvents []*staking.Event
+	for _, ev := range events {
+		if ev.TxHash.IsEmpty() {
+			blkEvents = append(blkEvents, ev)
+		}
+	}
+	if len(blkEvents) > 0 {
+		d.decodeStakingEvents(d.getOrCreateTx(blkHash), blkEvents)
 	}
 	return nil
 }
 
-func (d *transactionsDecoder) DecodeBlock(blkHash hash.Hash, events []*staking.Event) error {
+// isStakingReward returns true if the given event adds a staking reward from
+// the common pool to an escrow account's active pool.
+func isStakingReward(ev *staking.Event) bool {
+	return ev.Escrow != nil && ev.Escrow.Add != nil && ev.Escrow.Add.Owner.Equal(staking.CommonPoolAddress)
+}
+
+// rewardPools returns the active pools of the escrow accounts that receive
+// staking rewards as they were before each reward.  The pools after the block
+// are obtained with escrowPool, and the events of the block (which are in
+// the order in which they were emitted) are undone in reverse order.  Escrow
+// accounts whose pools are inconsistent with the events are omitted.
+func rewardPools(events []*staking.Event, escrowPool escrowPoolFunc) (map[*staking.Event]*staking.SharePool, error) {
+	pools := make(map[staking.Address]*staking.SharePool)
 	for _, ev := range events {
-		// We put all block-level events under an empty "transaction". All other events are skipped
-		// as they have already been processed during DecodeTx.
-		if !ev.TxHash.IsEmpty() {
+		if !isStakingReward(ev) {
+			continue
+		}
+		escrow := ev.Escrow.Add.Escrow
+		if _, ok := pools[escrow]; ok {
+			continue
+		}
+		pool, err := escrowPool(escrow)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get escrow pool of %s: %w", escrow, err)
+		}
+		pools[escrow] = &staking.SharePool{
+			Balance:     *pool.Balance.Clone(),
+			TotalShares: *pool.TotalShares.Clone(),
+		}
+	}
+	if len(pools) == 0 {
+		return nil, nil
+	}
+
+	before := make(map[*staking.Event]*staking.SharePool)
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		if ev.Escrow == nil {
 			continue
 		}
 
-		rosettaTx := d.getOrCreateTx(blkHash)
-		d.decodeStakingEvent(rosettaTx, ev)
+		var (
+			escrow staking.Address
+			err    error
+		)
+		switch ee := ev.Escrow; {
+		case ee.Add != nil:
+			escrow = ee.Add.Escrow
+			if pool, ok := pools[escrow]; ok {
+				if err = pool.Balance.Sub(&ee.Add.Amount); err == nil {
+					err = pool.TotalShares.Sub(&ee.Add.NewShares)
+				}
+			}
+		case ee.Take != nil:
+			escrow = ee.Take.Owner
+			if pool, ok := pools[escrow]; ok {
+				activeAmount := ee.Take.Amount.Clone()
+				if activeAmount.Sub(&ee.Take.DebondingAmount) != nil {
+					// Malformed event, attribute everything to the active pool.
+					activeAmount = ee.Take.Amount.Clone()
+				}
+				err = pool.Balance.Add(activeAmount)
+			}
+		case ee.DebondingStart != nil:
+			escrow = ee.DebondingStart.Escrow
+			if pool, ok := pools[escrow]; ok {
+				if err = pool.Balance.Add(&ee.DebondingStart.Amount); err == nil {
+					err = pool.TotalShares.Add(&ee.DebondingStart.ActiveShares)
+				}
+			}
+		}
+
+		pool, ok := pools[escrow]
+		switch {
+		case !ok:
+		case err != nil:
+			delete(pools, escrow)
+		case isStakingReward(ev):
+			before[ev] = &staking.SharePool{
+				Balance:     *pool.Balance.Clone(),
+				TotalShares: *pool.TotalShares.Clone(),
+			}
+		}
 	}
-	return nil
+	return before, nil
 }
 
 func (d *transactionsDecoder) Transactions() []*types.Transaction {
 	return d.txs
 }
 
+// Transaction returns the decoded transaction with the given hash or nil if
+// there is no such transaction.
+func (d *transactionsDecoder) Transaction(txHash hash.Hash) *types.Transaction {
+	return d.index[txHash]
+}
+
 func (d *transactionsDecoder) getOrCreateTx(txHash hash.Hash) *types.Transaction {
 	if tx, exists := d.index[txHash]; exists {
 		return tx
@@ -149,14 +482,37 @@ func (d *transactionsDecoder) getOrCreateTx(txHash hash.Hash) *types.Transaction
 }
 
 func (d *transactionsDecoder) decodeEvents(tx *types.Transaction, events []*results.Event) {
+	// We are only interested in staking events.
+	var stakingEvents []*staking.Event
 	for _, ev := range events {
-		// We are only interested in staking events.
-		if ev.Staking == nil {
-			continue
+		if ev.Staking != nil {
+			stakingEvents = append(stakingEvents, ev.Staking)
+		}
+	}
+	d.decodeStakingEvents(tx, stakingEvents)
+}
+
+func (d *transactionsDecoder) decodeStakingEvents(tx *types.Transaction, events []*staking.Event) {
+	for i, ev := range events {
+		var next *staking.Event
+		if i+1 < len(events) {
+			next = events[i+1]
 		}
+		d.decodeStakingEvent(tx, ev, next)
+	}
+}
 
-		d.decodeStakingEvent(tx, ev.Staking)
+// isCommission returns true if the given transfer from the common pool is a
+// commission, i.e. if the next event escrows the transferred amount with the
+// recipient as both the owner and the escrow account.
+func isCommission(transfer *staking.TransferEvent, next *staking.Event) bool {
+	if next == nil || next.Escrow == nil || next.Escrow.Add == nil {
+		return false
 	}
+	add := next.Escrow.Add
+	return add.Owner.Equal(transfer.To) &&
+		add.Escrow.Equal(transfer.To) &&
+		add.Amount.Cmp(&transfer.Amount) == 0
 }
 
 func appendOp(
@@ -164,6 +520,21 @@ func appendOp(
 	kind, acct string,
 	subacct *types.SubAccountIdentifier,
 	amt string,
+) []*types.Operation {
+	ops = appendNoAmountOp(ops, kind, acct, subacct)
+	ops[len(ops)-1].Amount = &types.Amount{
+		Value:    amt,
+		Currency: OasisCurrency,
+	}
+	return ops
+}
+
+// appendNoAmountOp appends an operation that doesn't change the balance of
+// the given account.
+func appendNoAmountOp(
+	ops []*types.Operation,
+	kind, acct string,
+	subacct *types.SubAccountIdentifier,
 ) []*types.Operation {
 	opIndex := int64(len(ops))
 	status := OpStatusOK
@@ -177,10 +548,6 @@ func appendOp(
 			Address:    acct,
 			SubAccount: subacct,
 		},
-		Amount: &types.Amount{
-			Value:    amt,
-			Currency: OasisCurrency,
-		},
 	}
 
 	// Add related operation if it exists.
@@ -195,19 +562,76 @@ func appendOp(
 	return append(ops, op)
 }
 
-func (d *transactionsDecoder) decodeStakingEvent(tx *types.Transaction, ev *staking.Event) {
+// appendRewardOps appends a pair of reward operations, moving the given amount
+// from the common pool to the given account.
+func appendRewardOps(
+	ops []*types.Operation,
+	acct string,
+	subacct *types.SubAccountIdentifier,
+	amt string,
+	rewardType string,
+) []*types.Operation {
+	ops = appendOp(
+		ops,
+		OpReward,
+		StringFromAddress(staking.CommonPoolAddress),
+		nil,
+		"-"+amt,
+	)
+	ops = appendOp(
+		ops,
+		OpReward,
+		acct,
+		subacct,
+		amt,
+	)
+	ops[len(ops)-1].Metadata = map[string]interface{}{
+		RewardTypeKey: rewardType,
+	}
+	return ops
+}
+
+// decodeStakingEvent appends the operations for the given staking event.  The
+// next event (if any) is used to tell commissions from other rewards.
+func (d *transactionsDecoder) decodeStakingEvent(tx *types.Transaction, ev, next *staking.Event) {
 	switch {
+	case ev.Transfer != nil && ev.Transfer.From.Equal(staking.CommonPoolAddress):
+		// Commission is transferred from the common pool to the escrow
+		// account's owner and then escrowed.  Other rewards (e.g. runtime
+		// rewards) are just transferred.
+		rewardType := RewardTypeGeneral
+		if isCommission(ev.Transfer, next) {
+			rewardType = RewardTypeCommission
+		}
+		tx.Operations = appendRewardOps(
+			tx.Operations,
+			StringFromAddress(ev.Transfer.To),
+			nil,
+			ev.Transfer.Amount.String(),
+			rewardType,
+		)
 	case ev.Transfer != nil:
+		kind := OpTransfer
+		switch {
+		case ev.Transfer.To.Equal(staking.GovernanceDepositsAddress):
+			// Proposal deposits are transferred to the governance deposits account.
+			kind = OpSubmitProposal
+		case ev.Transfer.From.Equal(staking.GovernanceDepositsAddress) &&
+			!ev.Transfer.To.Equal(staking.CommonPoolAddress):
+			// Deposits of closed proposals are refunded to the submitter (or
+			// transferred to the common pool if the proposal was rejected).
+			kind = OpDepositRefund
+		}
 		tx.Operations = appendOp(
 			tx.Operations,
-			OpTransfer,
+			kind,
 			StringFromAddress(ev.Transfer.From),
 			nil,
 			"-"+ev.Transfer.Amount.String(),
 		)
 		tx.Operations = appendOp(
 			tx.Operations,
-			OpTransfer,
+			kind,
 			StringFromAddress(ev.Transfer.To),
 			nil,
 			ev.Transfer.Amount.String(),
@@ -223,8 +647,23 @@ func (d *transactionsDecoder) decodeStakingEvent(tx *types.Transaction, ev *stak
 	case ev.Escrow != nil:
 		ee := ev.Escrow
 		switch {
+		case ee.Add != nil && ee.Add.Owner.Equal(staking.CommonPoolAddress):
+			// Staking rewards are added from the common pool to the escrow
+			// account's active pool.
+			tx.Operations = appendRewardOps(
+				tx.Operations,
+				StringFromAddress(ee.Add.Escrow),
+				&types.SubAccountIdentifier{Address: SubAccountEscrowActive},
+				ee.Add.Amount.String(),
+				RewardTypeStaking,
+			)
+			if pool, ok := d.rewardPools[ev]; ok {
+				md := tx.Operations[len(tx.Operations)-1].Metadata
+				md[RewardEscrowTotalSharesKey] = pool.TotalShares.String()
+				md[RewardEscrowActiveBalanceKey] = pool.Balance.String()
+			}
 		case ee.Add != nil:
-			// Owner's general account -> escrow account.
+			// Owner's general account -> escrow account's active pool.
 			tx.Operations = appendOp(
 				tx.Operations,
 				OpTransfer,
@@ -236,31 +675,80 @@ func (d *transactionsDecoder) decodeStakingEvent(tx *types.Transaction, ev *stak
 				tx.Operations,
 				OpTransfer,
 				StringFromAddress(ee.Add.Escrow),
-				&types.SubAccountIdentifier{Address: SubAccountEscrow},
+				&types.SubAccountIdentifier{Address: SubAccountEscrowActive},
 				ee.Add.Amount.String(),
 			)
 		case ee.Take != nil:
+			// Escrow account's active and debonding pools -> common pool.
+			activeAmount := ee.Take.Amount.Clone()
+			debondingAmount := ee.Take.DebondingAmount.Clone()
+			if err := activeAmount.Sub(debondingAmount); err != nil {
+				// Malformed event, attribute everything to the active pool.
+				activeAmount = ee.Take.Amount.Clone()
+				debondingAmount = quantity.NewQuantity()
+			}
+			if !activeAmount.IsZero() {
+				tx.Operations = appendOp(
+					tx.Operations,
+					OpSlash,
+					StringFromAddress(ee.Take.Owner),
+					&types.SubAccountIdentifier{Address: SubAccountEscrowActive},
+					"-"+activeAmount.String(),
+				)
+			}
+			if !debondingAmount.IsZero() {
+				tx.Operations = appendOp(
+					tx.Operations,
+					OpSlash,
+					StringFromAddress(ee.Take.Owner),
+					&types.SubAccountIdentifier{Address: SubAccountEscrowDebonding},
+					"-"+debondingAmount.String(),
+				)
+			}
 			tx.Operations = appendOp(
 				tx.Operations,
-				OpTransfer,
-				StringFromAddress(ee.Take.Owner),
-				&types.SubAccountIdentifier{Address: SubAccountEscrow},
-				"-"+ee.Take.Amount.String(),
-			)
-			tx.Operations = appendOp(
-				tx.Operations,
-				OpTransfer,
+				OpSlash,
 				StringFromAddress(staking.CommonPoolAddress),
 				nil,
 				ee.Take.Amount.String(),
 			)
+			tx.Operations[len(tx.Operations)-1].Metadata = map[string]interface{}{
+				SlashedEscrowKey:          StringFromAddress(ee.Take.Owner),
+				SlashedDebondingAmountKey: debondingAmount.String(),
+			}
+		case ee.DebondingStart != nil:
+			// Escrow account's active pool -> escrow account's debonding pool.
+			ds := ee.DebondingStart
+			tx.Operations = appendOp(
+				tx.Operations,
+				OpDebondingStart,
+				StringFromAddress(ds.Escrow),
+				&types.SubAccountIdentifier{Address: SubAccountEscrowActive},
+				"-"+ds.Amount.String(),
+			)
+			tx.Operations[len(tx.Operations)-1].Metadata = map[string]interface{}{
+				DebondingOwnerKey:     StringFromAddress(ds.Owner),
+				ActiveSharesChangeKey: ds.ActiveShares.String(),
+			}
+			tx.Operations = appendOp(
+				tx.Operations,
+				OpDebondingStart,
+				StringFromAddress(ds.Escrow),
+				&types.SubAccountIdentifier{Address: SubAccountEscrowDebonding},
+				ds.Amount.String(),
+			)
+			tx.Operations[len(tx.Operations)-1].Metadata = map[string]interface{}{
+				DebondingOwnerKey:        StringFromAddress(ds.Owner),
+				DebondingSharesChangeKey: ds.DebondingShares.String(),
+				DebondEndEpochKey:        uint64(ds.DebondEndTime),
+			}
 		case ee.Reclaim != nil:
-			// Escrow account -> owner's general account.
+			// Escrow account's debonding pool -> owner's general account.
 			tx.Operations = appendOp(
 				tx.Operations,
 				OpTransfer,
 				StringFromAddress(ee.Reclaim.Escrow),
-				&types.SubAccountIdentifier{Address: SubAccountEscrow},
+				&types.SubAccountIdentifier{Address: SubAccountEscrowDebonding},
 				"-"+ee.Reclaim.Amount.String(),
 			)
 			tx.Operations = appendOp(
@@ -271,6 +759,28 @@ func (d *transactionsDecoder) decodeStakingEvent(tx *types.Transaction, ev *stak
 				ee.Reclaim.Amount.String(),
 			)
 		}
+	case ev.AllowanceChange != nil:
+		// Allowance changes don't affect balances, so emit operations without
+		// amounts (like the parsed Allow transaction) with the change in the
+		// beneficiary's metadata.
+		ac := ev.AllowanceChange
+		tx.Operations = appendNoAmountOp(
+			tx.Operations,
+			OpAllow,
+			StringFromAddress(ac.Owner),
+			nil,
+		)
+		tx.Operations = appendNoAmountOp(
+			tx.Operations,
+			OpAllow,
+			StringFromAddress(ac.Beneficiary),
+			nil,
+		)
+		tx.Operations[len(tx.Operations)-1].Metadata = map[string]interface{}{
+			AllowanceNegativeKey:     ac.Negative,
+			AllowanceAmountChangeKey: ac.AmountChange.String(),
+			AllowanceKey:             ac.Allowance.String(),
+		}
 	}
 }
 
@@ -283,6 +793,10 @@ func newTransactionsDecoder() *transactionsDecoder {
 
 type operationToTransactionMapper struct {
 	ops []*types.Operation
+
+	// reclaimEscrowShares are the shares to reclaim for a reclaim escrow
+	// intent that specifies an amount.
+	reclaimEscrowShares *quantity.Quantity
 }
 
 // HasFee verifies whether the given operation list contains fee payment operations.
@@ -433,8 +947,9 @@ func getStakingAddEscrow(ops []*types.Operation) (*staking.Escrow, error) {
 }
 
 // getStakingReclaimEscrow decodes the Oasis staking reclaim escrow transaction
-// from the given Rosetta operations.
-func getStakingReclaimEscrow(ops []*types.Operation) (*staking.ReclaimEscrow, error) {
+// from the given Rosetta operations.  The given shares are used if the
+// operations specify an amount instead of shares.
+func getStakingReclaimEscrow(ops []*types.Operation, amountShares *quantity.Quantity) (*staking.ReclaimEscrow, error) {
 	if ops[0].Amount != nil {
 		return nil, fmt.Errorf("invalid reclaim escrow's from amount (expected: nil): %s", ops[0].Amount.Value)
 	}
@@ -447,7 +962,20 @@ func getStakingReclaimEscrow(ops []*types.Operation) (*staking.ReclaimEscrow, er
 		return nil, fmt.Errorf("invalid reclaim escrow's to amount (expected: nil): %s", ops[1].Amount.Value)
 	}
 	sharesRaw, ok := ops[1].Metadata[ReclaimEscrowSharesKey]
-	if !ok {
+	_, hasAmount := ops[1].Metadata[ReclaimEscrowAmountKey]
+	switch {
+	case ok && hasAmount:
+		return nil, fmt.Errorf("both reclaim escrow shares and amount metadata specified")
+	case hasAmount:
+		if amountShares == nil {
+			return nil, fmt.Errorf("reclaim escrow amount not converted to shares")
+		}
+		reclaim := staking.ReclaimEscrow{
+			Account: escrowAccount,
+			Shares:  *amountShares.Clone(),
+		}
+		return &reclaim, nil
+	case !ok:
 		return nil, fmt.Errorf("reclaim escrow shares metadata not specified")
 	}
 	sharesStr, ok := sharesRaw.(string)
@@ -466,6 +994,193 @@ func getStakingReclaimEscrow(ops []*types.Operation) (*staking.ReclaimEscrow, er
 	return &reclaim, nil
 }
 
+// getStakingAllow decodes the Oasis staking allow transaction from the given
+// Rosetta operations.
+func getStakingAllow(ops []*types.Operation) (*staking.Allow, error) {
+	if ops[0].Amount != nil {
+		return nil, fmt.Errorf("invalid allow's owner amount (expected: nil): %s", ops[0].Amount.Value)
+	}
+
+	var beneficiary staking.Address
+	if err := beneficiary.UnmarshalText([]byte(ops[1].Account.Address)); err != nil {
+		return nil, fmt.Errorf("invalid allow beneficiary address (%s): %w", ops[1].Account.Address, err)
+	}
+	if ops[1].Amount != nil {
+		return nil, fmt.Errorf("invalid allow's beneficiary amount (expected: nil): %s", ops[1].Amount.Value)
+	}
+
+	var negative bool
+	if negativeRaw, ok := ops[1].Metadata[AllowanceNegativeKey]; ok {
+		if negative, ok = negativeRaw.(bool); !ok {
+			return nil, fmt.Errorf("malformed allow negative metadata")
+		}
+	}
+	amountChangeRaw, ok := ops[1].Metadata[AllowanceAmountChangeKey]
+	if !ok {
+		return nil, fmt.Errorf("allow amount change metadata not specified")
+	}
+	amountChangeStr, ok := amountChangeRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("malformed allow amount change metadata")
+	}
+	var amountChange quantity.Quantity
+	if err := amountChange.UnmarshalText([]byte(amountChangeStr)); err != nil {
+		return nil, fmt.Errorf("malformed allow amount change metadata (%s): %w", amountChangeStr, err)
+	}
+
+	allow := staking.Allow{
+		Beneficiary:  beneficiary,
+		Negative:     negative,
+		AmountChange: amountChange,
+	}
+	return &allow, nil
+}
+
+// getGovernanceCastVote decodes the Oasis governance cast vote transaction
+// from the given Rosetta operations.
+func getGovernanceCastVote(ops []*types.Operation) (*governance.ProposalVote, error) {
+	if ops[0].Amount != nil {
+		return nil, fmt.Errorf("invalid cast vote's amount (expected: nil): %s", ops[0].Amount.Value)
+	}
+
+	proposalIDRaw, ok := ops[0].Metadata[ProposalIDKey]
+	if !ok {
+		return nil, fmt.Errorf("cast vote proposal ID metadata not specified")
+	}
+	proposalID, err := uint64FromMetadata(proposalIDRaw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cast vote proposal ID metadata: %w", err)
+	}
+	voteRaw, ok := ops[0].Metadata[VoteKey]
+	if !ok {
+		return nil, fmt.Errorf("cast vote vote metadata not specified")
+	}
+	voteStr, ok := voteRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("malformed cast vote vote metadata")
+	}
+	var vote governance.Vote
+	if err := vote.UnmarshalText([]byte(voteStr)); err != nil {
+		return nil, fmt.Errorf("malformed cast vote vote metadata (%s): %w", voteStr, err)
+	}
+
+	proposalVote := governance.ProposalVote{
+		ID:   proposalID,
+		Vote: vote,
+	}
+	return &proposalVote, nil
+}
+
+// maxExactFloat64 is the smallest integer above which not all integers can be
+// represented exactly as a float64.
+const maxExactFloat64 = 1 << 53
+
+// uint64FromMetadata converts the given metadata value to a non-negative
+// integer.  The value may be a decimal string or a JSON number.  Numbers
+// of 2^53 and above must be given as strings, since they may have been rounded
+// when they were decoded.
+func uint64FromMetadata(raw interface{}) (uint64, error) {
+	switch v := raw.(type) {
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	case json.Number:
+		return strconv.ParseUint(v.String(), 10, 64)
+	case uint64:
+		return v, nil
+	case float64:
+		if v < 0 || v >= maxExactFloat64 || v != math.Trunc(v) {
+			return 0, fmt.Errorf("not an integer in [0, 2^53): %v", v)
+		}
+		return uint64(v), nil
+	default:
+		return 0, fmt.Errorf("unsupported type %T", raw)
+	}
+}
+
+// getCall decodes a generic Oasis consensus transaction from the given
+// Rosetta operations.
+func getCall(ops []*types.Operation) (transaction.MethodName, cbor.RawMessage, error) {
+	if ops[0].Amount != nil {
+		return "", nil, fmt.Errorf("invalid call's amount (expected: nil): %s", ops[0].Amount.Value)
+	}
+
+	methodRaw, ok := ops[0].Metadata[CallMethodKey]
+	if !ok {
+		return "", nil, fmt.Errorf("call method metadata not specified")
+	}
+	methodStr, ok := methodRaw.(string)
+	if !ok {
+		return "", nil, fmt.Errorf("malformed call method metadata")
+	}
+	method := transaction.MethodName(methodStr)
+	if err := method.SanityCheck(); err != nil {
+		return "", nil, fmt.Errorf("malformed call method metadata (%s): %w", methodStr, err)
+	}
+	if stakingTxMethods[method] || method == governance.MethodCastVote {
+		return "", nil, fmt.Errorf("call method %s must use its own operations", method)
+	}
+
+	bodyRaw, hasBody := ops[0].Metadata[CallBodyKey]
+	bodyCBORRaw, hasBodyCBOR := ops[0].Metadata[CallBodyCBORKey]
+	switch {
+	case hasBody && hasBodyCBOR:
+		return "", nil, fmt.Errorf("both call body and call CBOR body metadata specified")
+	case hasBody:
+		bodyType := method.BodyType()
+		if bodyType == nil {
+			return "", nil, fmt.Errorf("unknown body type of call method %s", method)
+		}
+		rawBody, err := json.Marshal(bodyRaw)
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed call body metadata: %w", err)
+		}
+		body := reflect.New(reflect.TypeOf(bodyType)).Interface()
+		if err = json.Unmarshal(rawBody, body); err != nil {
+			return "", nil, fmt.Errorf("malformed call body metadata: %w", err)
+		}
+		return method, cbor.Marshal(body), nil
+	case hasBodyCBOR:
+		bodyCBORStr, ok := bodyCBORRaw.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("malformed call CBOR body metadata")
+		}
+		body, err := base64.StdEncoding.DecodeString(bodyCBORStr)
+		if err != nil {
+			return "", nil, fmt.Errorf("malformed call CBOR body metadata: %w", err)
+		}
+		return method, body, nil
+	default:
+		return method, nil, nil
+	}
+}
+
+// getStakingWithdraw decodes the Oasis staking withdraw transaction from the
+// given Rosetta operations.
+func getStakingWithdraw(ops []*types.Operation) (*staking.Withdraw, error) {
+	var from staking.Address
+	if err := from.UnmarshalText([]byte(ops[0].Account.Address)); err != nil {
+		return nil, fmt.Errorf("invalid withdraw's from address (%s): %w", ops[0].Account.Address, err)
+	}
+	amount, err := readOasisCurrencyNeg(ops[0].Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid withdraw from amount: %w", err)
+	}
+
+	amount2, err := readOasisCurrency(ops[1].Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid withdraw to amount: %w", err)
+	}
+	if amount.Cmp(amount2) != 0 {
+		return nil, fmt.Errorf("withdraw amounts differ between operations (from: %s to: %s)", amount, amount2)
+	}
+
+	withdraw := staking.Withdraw{
+		From:   from,
+		Amount: *amount,
+	}
+	return &withdraw, nil
+}
+
 // checkSigner ensures the operation's signer address matches the given signer
 // address (if specified) and returns the operation's signer address.
 func checkOpSignerAddress(op *types.Operation, signerAddr string) (string, error) {
@@ -491,8 +1206,19 @@ const (
 	KindStakingBurn          TransactionKind = 2
 	KindStakingAddEscrow     TransactionKind = 3
 	KindStakingReclaimEscrow TransactionKind = 4
+	KindStakingAllow         TransactionKind = 5
+	KindStakingWithdraw      TransactionKind = 6
+	KindGovernanceCastVote   TransactionKind = 7
+	KindCall                 TransactionKind = 8
 )
 
+// isActiveEscrowSubAccount returns true if the given sub-account is the active
+// escrow pool, which intents may also refer to as the escrow sub-account.
+func isActiveEscrowSubAccount(subAccount *types.SubAccountIdentifier) bool {
+	return subAccount != nil &&
+		(subAccount.Address == SubAccountEscrowActive || subAccount.Address == SubAccountEscrow)
+}
+
 // decodeOpsToTransactionKind decodes the Oasis transaction kind from the given
 // Rosetta operations.
 func decodeOpsToTransactionKind(ops []*types.Operation) TransactionKind {
@@ -502,6 +1228,12 @@ func decodeOpsToTransactionKind(ops []*types.Operation) TransactionKind {
 		case ops[0].Type == OpBurn &&
 			ops[0].Account.SubAccount == nil:
 			return KindStakingBurn
+		case ops[0].Type == OpCastVote &&
+			ops[0].Account.SubAccount == nil:
+			return KindGovernanceCastVote
+		case ops[0].Type == OpCall &&
+			ops[0].Account.SubAccount == nil:
+			return KindCall
 		default:
 			return KindUnknown
 		}
@@ -513,17 +1245,25 @@ func decodeOpsToTransactionKind(ops []*types.Operation) TransactionKind {
 			switch {
 			case ops[1].Account.SubAccount == nil:
 				return KindStakingTransfer
-			case ops[1].Account.SubAccount != nil &&
-				ops[1].Account.SubAccount.Address == SubAccountEscrow:
+			case isActiveEscrowSubAccount(ops[1].Account.SubAccount):
 				return KindStakingAddEscrow
 			default:
 				return KindUnknown
 			}
 		case ops[0].Type == OpReclaimEscrow &&
 			ops[1].Type == OpReclaimEscrow &&
-			ops[1].Account.SubAccount != nil &&
-			ops[1].Account.SubAccount.Address == SubAccountEscrow:
+			isActiveEscrowSubAccount(ops[1].Account.SubAccount):
 			return KindStakingReclaimEscrow
+		case ops[0].Type == OpAllow &&
+			ops[0].Account.SubAccount == nil &&
+			ops[1].Type == OpAllow &&
+			ops[1].Account.SubAccount == nil:
+			return KindStakingAllow
+		case ops[0].Type == OpWithdraw &&
+			ops[0].Account.SubAccount == nil &&
+			ops[1].Type == OpWithdraw &&
+			ops[1].Account.SubAccount == nil:
+			return KindStakingWithdraw
 		default:
 			return KindUnknown
 		}
@@ -548,11 +1288,16 @@ func (m *operationToTransactionMapper) GetTransaction() (string, *transaction.Tr
 
 	decodedTxnKind := decodeOpsToTransactionKind(remainingOps)
 
-	if decodedTxnKind != KindUnknown {
+	switch decodedTxnKind {
+	case KindUnknown:
+	case KindStakingWithdraw:
+		// The withdrawing beneficiary is the receiving side.
+		signerAddr, err = checkOpSignerAddress(remainingOps[1], signerAddr)
+	default:
 		signerAddr, err = checkOpSignerAddress(remainingOps[0], signerAddr)
-		if err != nil {
-			return "", nil, err
-		}
+	}
+	if err != nil {
+		return "", nil, err
 	}
 
 	var method transaction.MethodName
@@ -581,11 +1326,37 @@ func (m *operationToTransactionMapper) GetTransaction() (string, *transaction.Tr
 		body = cbor.Marshal(addEscrow)
 	case KindStakingReclaimEscrow:
 		method = staking.MethodReclaimEscrow
-		reclaimEscrow, err2 := getStakingReclaimEscrow(remainingOps)
+		reclaimEscrow, err2 := getStakingReclaimEscrow(remainingOps, m.reclaimEscrowShares)
 		if err2 != nil {
 			return "", nil, err2
 		}
 		body = cbor.Marshal(reclaimEscrow)
+	case KindStakingAllow:
+		method = staking.MethodAllow
+		allow, err2 := getStakingAllow(remainingOps)
+		if err2 != nil {
+			return "", nil, err2
+		}
+		body = cbor.Marshal(allow)
+	case KindStakingWithdraw:
+		method = staking.MethodWithdraw
+		withdraw, err2 := getStakingWithdraw(remainingOps)
+		if err2 != nil {
+			return "", nil, err2
+		}
+		body = cbor.Marshal(withdraw)
+	case KindGovernanceCastVote:
+		method = governance.MethodCastVote
+		proposalVote, err2 := getGovernanceCastVote(remainingOps)
+		if err2 != nil {
+			return "", nil, err2
+		}
+		body = cbor.Marshal(proposalVote)
+	case KindCall:
+		var err2 error
+		if method, body, err2 = getCall(remainingOps); err2 != nil {
+			return "", nil, err2
+		}
 	default:
 		return "", nil, fmt.Errorf("not supported")
 	}
@@ -599,8 +1370,48 @@ func (m *operationToTransactionMapper) GetTransaction() (string, *transaction.Tr
 	return signerAddr, tx, nil
 }
 
+// ReclaimEscrowAmount returns the escrow account and the amount to reclaim if
+// the operations are a reclaim escrow intent that specifies an amount instead
+// of shares.  The returned amount is nil otherwise.
+func (m *operationToTransactionMapper) ReclaimEscrowAmount() (*staking.Address, *quantity.Quantity, error) {
+	ops := m.ops
+	if m.HasFee() {
+		ops = ops[2:]
+	}
+	if decodeOpsToTransactionKind(ops) != KindStakingReclaimEscrow {
+		return nil, nil, nil
+	}
+	amountRaw, ok := ops[1].Metadata[ReclaimEscrowAmountKey]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	var escrowAccount staking.Address
+	if err := escrowAccount.UnmarshalText([]byte(ops[1].Account.Address)); err != nil {
+		return nil, nil, fmt.Errorf("invalid reclaim escrow address (%s): %w", ops[1].Account.Address, err)
+	}
+	amountStr, ok := amountRaw.(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("malformed reclaim escrow amount metadata")
+	}
+	var amount quantity.Quantity
+	if err := amount.UnmarshalText([]byte(amountStr)); err != nil {
+		return nil, nil, fmt.Errorf("malformed reclaim escrow amount metadata (%s): %w", amountStr, err)
+	}
+	if amount.IsZero() {
+		return nil, nil, fmt.Errorf("reclaim escrow amount must be positive")
+	}
+	return &escrowAccount, &amount, nil
+}
+
+// SetReclaimEscrowShares sets the shares to reclaim for a reclaim escrow
+// intent that specifies an amount.
+func (m *operationToTransactionMapper) SetReclaimEscrowShares(shares *quantity.Quantity) {
+	m.reclaimEscrowShares = shares
+}
+
 func newOperationToTransactionMapper(ops []*types.Operation) *operationToTransactionMapper {
-	return &operationToTransactionMapper{ops}
+	return &operationToTransactionMapper{ops: ops}
 }
 
 type transactionToOperationMapper struct {
@@ -772,7 +1583,7 @@ func (m *transactionToOperationMapper) emitAddEscrowOps() error {
 			Account: &types.AccountIdentifier{
 				Address: StringFromAddress(body.Account),
 				SubAccount: &types.SubAccountIdentifier{
-					Address: SubAccountEscrow,
+					Address: SubAccountEscrowActive,
 				},
 			},
 			Amount: &types.Amount{
@@ -819,7 +1630,7 @@ func (m *transactionToOperationMapper) emitReclaimEscrowOps() error {
 			Account: &types.AccountIdentifier{
 				Address: StringFromAddress(body.Account),
 				SubAccount: &types.SubAccountIdentifier{
-					Address: SubAccountEscrow,
+					Address: SubAccountEscrowActive,
 				},
 			},
 			Metadata: map[string]interface{}{
@@ -836,6 +1647,180 @@ func (m *transactionToOperationMapper) emitReclaimEscrowOps() error {
 	return nil
 }
 
+// emitAllowOps emits the required operations for the allow transaction.
+func (m *transactionToOperationMapper) emitAllowOps() error {
+	var body staking.Allow
+	if err := cbor.Unmarshal(m.tx.Body, &body); err != nil {
+		return fmt.Errorf("malformed body: %w", err)
+	}
+
+	opIndex := int64(len(m.ops))
+	m.ops = append(m.ops,
+		&types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: opIndex,
+			},
+			Type:   OpAllow,
+			Status: m.status,
+			Account: &types.AccountIdentifier{
+				Address: m.txSignerAddress,
+			},
+		},
+		&types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: opIndex + 1,
+			},
+			Type:   OpAllow,
+			Status: m.status,
+			Account: &types.AccountIdentifier{
+				Address: StringFromAddress(body.Beneficiary),
+			},
+			Metadata: map[string]interface{}{
+				AllowanceNegativeKey:     body.Negative,
+				AllowanceAmountChangeKey: body.AmountChange.String(),
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: opIndex,
+				},
+			},
+		},
+	)
+
+	return nil
+}
+
+// emitWithdrawOps emits the required operations for the withdraw transaction.
+func (m *transactionToOperationMapper) emitWithdrawOps() error {
+	var body staking.Withdraw
+	if err := cbor.Unmarshal(m.tx.Body, &body); err != nil {
+		return fmt.Errorf("malformed body: %w", err)
+	}
+
+	opIndex := int64(len(m.ops))
+	m.ops = append(m.ops,
+		&types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: opIndex,
+			},
+			Type:   OpWithdraw,
+			Status: m.status,
+			Account: &types.AccountIdentifier{
+				Address: StringFromAddress(body.From),
+			},
+			Amount: &types.Amount{
+				Value:    "-" + body.Amount.String(),
+				Currency: OasisCurrency,
+			},
+		},
+		&types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: opIndex + 1,
+			},
+			Type:   OpWithdraw,
+			Status: m.status,
+			Account: &types.AccountIdentifier{
+				Address: m.txSignerAddress,
+			},
+			Amount: &types.Amount{
+				Value:    body.Amount.String(),
+				Currency: OasisCurrency,
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: opIndex,
+				},
+			},
+		},
+	)
+
+	return nil
+}
+
+// emitCastVoteOps emits the required operations for the cast vote
+// transaction.
+func (m *transactionToOperationMapper) emitCastVoteOps() error {
+	var body governance.ProposalVote
+	if err := cbor.Unmarshal(m.tx.Body, &body); err != nil {
+		return fmt.Errorf("malformed body: %w", err)
+	}
+
+	m.ops = append(m.ops,
+		&types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: int64(len(m.ops)),
+			},
+			Type:   OpCastVote,
+			Status: m.status,
+			Account: &types.AccountIdentifier{
+				Address: m.txSignerAddress,
+			},
+			Metadata: map[string]interface{}{
+				// Encode the identifier as a string, since JSON clients may
+				// decode large numbers with a loss of precision.
+				ProposalIDKey: strconv.FormatUint(body.ID, 10),
+				VoteKey:       body.Vote.String(),
+			},
+		},
+	)
+
+	return nil
+}
+
+// emitCallOps emits the generic call operation for the transaction.
+func (m *transactionToOperationMapper) emitCallOps() {
+	md := map[string]interface{}{
+		CallMethodKey: string(m.tx.Method),
+	}
+	if len(m.tx.Body) > 0 {
+		body, err := decodeCallBody(m.tx)
+		switch err {
+		case nil:
+			md[CallBodyKey] = body
+		default:
+			md[CallBodyCBORKey] = base64.StdEncoding.EncodeToString(m.tx.Body)
+		}
+	}
+
+	m.ops = append(m.ops,
+		&types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: int64(len(m.ops)),
+			},
+			Type:   OpCall,
+			Status: m.status,
+			Account: &types.AccountIdentifier{
+				Address: m.txSignerAddress,
+			},
+			Metadata: md,
+		},
+	)
+}
+
+// decodeCallBody decodes the body of the given transaction into its JSON
+// representation.
+func decodeCallBody(tx *transaction.Transaction) (interface{}, error) {
+	bodyType := tx.Method.BodyType()
+	if bodyType == nil {
+		return nil, fmt.Errorf("unknown method: %s", tx.Method)
+	}
+	body := reflect.New(reflect.TypeOf(bodyType)).Interface()
+	if err := cbor.Unmarshal(tx.Body, body); err != nil {
+		return nil, fmt.Errorf("malformed body: %w", err)
+	}
+
+	// Convert the body into generic JSON values.
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal body: %w", err)
+	}
+	var jsonBody interface{}
+	if err = json.Unmarshal(rawBody, &jsonBody); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	return jsonBody, nil
+}
+
 // EmitTxOps emits the required transaction-specific operations.
 func (m *transactionToOperationMapper) EmitTxOps() error {
 	switch m.tx.Method {
@@ -847,8 +1832,15 @@ func (m *transactionToOperationMapper) EmitTxOps() error {
 		return m.emitAddEscrowOps()
 	case staking.MethodReclaimEscrow:
 		return m.emitReclaimEscrowOps()
+	case staking.MethodAllow:
+		return m.emitAllowOps()
+	case staking.MethodWithdraw:
+		return m.emitWithdrawOps()
+	case governance.MethodCastVote:
+		return m.emitCastVoteOps()
 	default:
-		// Other transactions do not affect balances so they do not emit any operations.
+		// Other transactions do not affect balances so they emit a generic call operation.
+		m.emitCallOps()
 	}
 	return nil
 }
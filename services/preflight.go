@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+)
+
+// SubmitPreflightEnvVar is the name of the environment variable that enables
+// checking transactions in /construction/submit before submitting them to the
+// node when set to a non-empty value.
+const SubmitPreflightEnvVar = "OASIS_ROSETTA_GATEWAY_SUBMIT_PREFLIGHT"
+
+// preflight checks the given signed transactions before they are submitted in
+// order: their signatures, nonces, fees and the signers' balances.
+func (s *constructionAPIService) preflight(ctx context.Context, txs []*transaction.SignedTransaction) *types.Error {
+	minGasPrice, err := s.oasisClient.GetMinGasPrice(ctx)
+	if err != nil {
+		loggerCons.Error("ConstructionSubmit: unable to get minimum gas price", "err", err)
+		return NewDetailedError(ErrUnableToEstimateFee, err)
+	}
+
+	// The allowed nonces of each signer's next transaction.
+	type nonceRange struct{ lo, hi uint64 }
+	nonces := make(map[staking.Address]nonceRange)
+	spends := make(map[staking.Address]*quantity.Quantity)
+	for i, stx := range txs {
+		// Opening verifies the signature for the current chain context.
+		var tx transaction.Transaction
+		if err = stx.Open(&tx); err != nil {
+			err = fmt.Errorf("transaction %d: %w", i, err)
+			loggerCons.Error("ConstructionSubmit: invalid signature", "err", err)
+			return NewDetailedError(ErrInvalidSignature, err)
+		}
+		if err = tx.SanityCheck(); err != nil {
+			err = fmt.Errorf("transaction %d: %w", i, err)
+			loggerCons.Error("ConstructionSubmit: malformed transaction", "err", err)
+			return NewDetailedError(ErrMalformedValue, err)
+		}
+		signer := staking.NewAddress(stx.Signature.PublicKey)
+
+		// The first transaction of a signer may follow its transactions in
+		// the mempool or resubmit one of them.  Later transactions of the
+		// same signer must have consecutive nonces.
+		nr, ok := nonces[signer]
+		if !ok {
+			if nr.lo, err = s.oasisClient.GetNextNonce(ctx, signer, oasis.LatestHeight); err != nil {
+				loggerCons.Error("ConstructionSubmit: unable to get next nonce",
+					"account_id", signer.String(),
+					"err", err,
+				)
+				return ErrUnableToGetNextNonce
+			}
+			nr.hi = nr.lo
+			// The mempool is only needed for nonces ahead of the next nonce.
+			if tx.Nonce > nr.lo {
+				if nr.hi, err = s.firstUnusedNonce(ctx, signer, nr.lo, tx.Nonce); err != nil {
+					loggerCons.Error("ConstructionSubmit: unable to get unconfirmed transactions", "err", err)
+					return ErrUnableToGetTxns
+				}
+			}
+		}
+		if tx.Nonce < nr.lo || tx.Nonce > nr.hi {
+			expected := fmt.Sprintf("%d", nr.lo)
+			if nr.hi > nr.lo {
+				expected = fmt.Sprintf("%d to %d", nr.lo, nr.hi)
+			}
+			err = fmt.Errorf("transaction %d: invalid nonce (got: %d expected: %s)", i, tx.Nonce, expected)
+			loggerCons.Error("ConstructionSubmit: invalid nonce", "err", err)
+			return NewDetailedError(ErrInvalidNonce, err)
+		}
+		nonces[signer] = nonceRange{lo: tx.Nonce + 1, hi: tx.Nonce + 1}
+
+		spend, err := txSpend(&tx, minGasPrice)
+		if err != nil {
+			err = fmt.Errorf("transaction %d: %w", i, err)
+			loggerCons.Error("ConstructionSubmit: invalid fee or amount", "err", err)
+			if errors.Is(err, errFeeTooLow) {
+				return NewDetailedError(ErrFeeTooLow, err)
+			}
+			return NewDetailedError(ErrMalformedValue, err)
+		}
+		if total, ok := spends[signer]; ok {
+			if err = spend.Add(total); err != nil {
+				loggerCons.Error("ConstructionSubmit: unable to add amounts", "err", err)
+				return NewDetailedError(ErrMalformedValue, err)
+			}
+		}
+		spends[signer] = spend
+	}
+
+	for signer, spend := range spends {
+		act, err := s.oasisClient.GetAccount(ctx, oasis.LatestHeight, signer)
+		if err != nil {
+			loggerCons.Error("ConstructionSubmit: unable to get account",
+				"account_id", signer.String(),
+				"err", err,
+			)
+			return ErrUnableToGetAccount
+		}
+		if act.General.Balance.Cmp(spend) < 0 {
+			err = fmt.Errorf("balance of %s is %s, need %s for amounts and fees",
+				signer.String(), act.General.Balance.String(), spend.String(),
+			)
+			loggerCons.Error("ConstructionSubmit: insufficient balance", "err", err)
+			return NewDetailedError(ErrInsufficientBalance, err)
+		}
+	}
+	return nil
+}
+
+// firstUnusedNonce returns the first nonce from the given next nonce that
+// isn't used by one of the signer's transactions in the mempool.  If that is
+// below the given wanted nonce, a new mempool snapshot is taken, since the
+// current one may be missing recently submitted transactions.
+func (s *constructionAPIService) firstUnusedNonce(
+	ctx context.Context,
+	signer staking.Address,
+	next uint64,
+	want uint64,
+) (uint64, error) {
+	nonce := next
+	for _, fresh := range []bool{false, true} {
+		pending, err := s.mempool.signerNonces(ctx, signer, fresh)
+		if err != nil {
+			return 0, err
+		}
+		nonce = next
+		for pending[nonce] {
+			nonce++
+		}
+		if nonce >= want {
+			break
+		}
+	}
+	return nonce, nil
+}
+
+// errFeeTooLow is returned by txSpend if the fee is below the minimum gas
+// price.
+var errFeeTooLow = errors.New("fee below minimum gas price")
+
+// txSpend returns the amount that the given transaction takes from the
+// signer's general balance, including the fee.  It also checks that the fee
+// pays at least the given minimum gas price.
+func txSpend(tx *transaction.Transaction, minGasPrice *quantity.Quantity) (*quantity.Quantity, error) {
+	fee := tx.Fee
+	if fee == nil {
+		fee = &transaction.Fee{}
+	}
+	minFee := minGasPrice.Clone()
+	if err := minFee.Mul(quantity.NewFromUint64(uint64(fee.Gas))); err != nil {
+		return nil, err
+	}
+	if fee.Amount.Cmp(minFee) < 0 {
+		return nil, fmt.Errorf("%w (fee: %s gas: %d minimum: %s)", errFeeTooLow, fee.Amount.String(), fee.Gas, minFee)
+	}
+
+	spend := fee.Amount.Clone()
+	var amount *quantity.Quantity
+	switch tx.Method {
+	case staking.MethodTransfer:
+		var body staking.Transfer
+		if err := cbor.Unmarshal(tx.Body, &body); err != nil {
+			return nil, fmt.Errorf("malformed body: %w", err)
+		}
+		amount = &body.Amount
+	case staking.MethodBurn:
+		var body staking.Burn
+		if err := cbor.Unmarshal(tx.Body, &body); err != nil {
+			return nil, fmt.Errorf("malformed body: %w", err)
+		}
+		amount = &body.Amount
+	case staking.MethodAddEscrow:
+		var body staking.Escrow
+		if err := cbor.Unmarshal(tx.Body, &body); err != nil {
+			return nil, fmt.Errorf("malformed body: %w", err)
+		}
+		amount = &body.Amount
+	default:
+		return spend, nil
+	}
+	if err := spend.Add(amount); err != nil {
+		return nil, err
+	}
+	return spend, nil
+}
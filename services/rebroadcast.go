@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
+)
+
+// RebroadcastRetriesEnvVar is the name of the environment variable that
+// specifies how many times the transaction tracker resubmits a transaction that
+// disappeared from the mempool before its nonce was used.
+// If unset or zero, such transactions are only marked as dropped.
+const RebroadcastRetriesEnvVar = "OASIS_ROSETTA_GATEWAY_REBROADCAST_RETRIES"
+
+// trackerMissingLookup is the number of blocks after its submission height in
+// which a tracked transaction is looked up before it expires.  The tracker
+// checks later blocks while the transaction is tracked, so it can only have
+// missed the transaction in blocks shortly after its submission (e.g. if the
+// gateway restarted in between).
+const trackerMissingLookup = 10
+
+// handleMissing handles a tracked transaction that is neither in the mempool
+// nor in a block up to the given height.  The transaction expires if its
+// signer's nonce has moved past it at that height, unless it is found in the
+// blocks after its submission.  Otherwise it is rebroadcast while the retry
+// budget lasts, and dropped after that.  Dropped transactions are checked
+// again only once the height has changed.
+func (t *Tracker) handleMissing(
+	ctx context.Context,
+	ttx *storage.TrackedTransaction,
+	height int64,
+	lastRetainedHeight int64,
+) error {
+	var stx transaction.SignedTransaction
+	if err := cbor.Unmarshal(ttx.SignedTransaction, &stx); err != nil {
+		return fmt.Errorf("malformed signed transaction: %w", err)
+	}
+	var tx transaction.Transaction
+	if err := cbor.Unmarshal(stx.Blob, &tx); err != nil {
+		return fmt.Errorf("malformed transaction: %w", err)
+	}
+
+	txHash := stx.Hash()
+	exhausted := ttx.State == TxStateDropped && ttx.Rebroadcasts >= t.rebroadcastRetries
+	if exhausted && t.droppedCheckedAt[txHash] == height {
+		return nil
+	}
+
+	signer := staking.NewAddress(stx.Signature.PublicKey)
+	nonce, err := t.oasisClient.GetNextNonce(ctx, signer, height)
+	if err != nil {
+		return fmt.Errorf("unable to get next nonce: %w", err)
+	}
+	if nonce > tx.Nonce {
+		found, err2 := t.lookupMissing(ctx, &stx, ttx, height, lastRetainedHeight)
+		if err2 != nil || found {
+			return err2
+		}
+		loggerTrk.Info("transaction expired",
+			"hash", ttx.Hash,
+			"nonce", tx.Nonce,
+			"next_nonce", nonce,
+		)
+		return t.setState(ttx, TxStateExpired)
+	}
+
+	if ttx.Rebroadcasts >= t.rebroadcastRetries {
+		if exhausted {
+			t.droppedCheckedAt[txHash] = height
+			return nil
+		}
+		loggerTrk.Info("transaction dropped from mempool", "hash", ttx.Hash)
+		t.droppedCheckedAt[txHash] = height
+		return t.setState(ttx, TxStateDropped)
+	}
+
+	state := TxStatePending
+	if err = t.oasisClient.SubmitTxNoWait(ctx, &stx); err != nil && !errors.Is(err, consensus.ErrDuplicateTx) {
+		loggerTrk.Warn("failed to rebroadcast transaction",
+			"hash", ttx.Hash,
+			"err", err,
+		)
+		state = TxStateDropped
+	} else {
+		loggerTrk.Info("rebroadcast transaction",
+			"hash", ttx.Hash,
+			"rebroadcasts", ttx.Rebroadcasts+1,
+		)
+	}
+	return t.modify(ttx, func(cur *storage.TrackedTransaction) {
+		cur.State = state
+		cur.SubmittedAt = time.Now().UnixMilli()
+		cur.SubmittedHeight = height
+		cur.Rebroadcasts++
+	})
+}
+
+// lookupMissing looks up the given missing transaction in the blocks after its
+// submission height up to the given height, and marks it as included if it is
+// found.  It returns true if the transaction was found.
+func (t *Tracker) lookupMissing(
+	ctx context.Context,
+	stx *transaction.SignedTransaction,
+	ttx *storage.TrackedTransaction,
+	height int64,
+	lastRetainedHeight int64,
+) (bool, error) {
+	from := ttx.SubmittedHeight + 1
+	if ttx.SubmittedHeight <= 0 {
+		// The submission height is unknown, so check the latest blocks.
+		from = height - trackerMissingLookup + 1
+	}
+	if from < lastRetainedHeight {
+		from = lastRetainedHeight
+	}
+	to := from + trackerMissingLookup - 1
+	if to > height {
+		to = height
+	}
+
+	txHash := stx.Hash()
+	missing := map[hash.Hash]*storage.TrackedTransaction{txHash: ttx}
+	for h := from; h <= to; h++ {
+		if err := t.checkBlock(ctx, h, missing); err != nil {
+			return false, err
+		}
+		if len(missing) == 0 {
+			loggerTrk.Info("found missing transaction",
+				"hash", ttx.Hash,
+				"height", h,
+			)
+			return true, nil
+		}
+	}
+	return false, nil
+}
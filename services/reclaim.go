@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+)
+
+// OptionsReclaimEscrowAccountKey is the name of the key in the Options map
+// inside a ConstructionMetadataRequest that specifies the escrow account of a
+// reclaim escrow intent that specifies an amount.  The amount is specified
+// under ReclaimEscrowAmountKey.
+const OptionsReclaimEscrowAccountKey = "reclaim_escrow_account"
+
+// reclaimEscrowSharesFromMetadata returns the shares to reclaim specified in
+// the given construction metadata.  It returns zero shares if the metadata is
+// nil.
+func reclaimEscrowSharesFromMetadata(md map[string]interface{}) (*quantity.Quantity, error) {
+	if md == nil {
+		return quantity.NewQuantity(), nil
+	}
+	sharesRaw, ok := md[ReclaimEscrowSharesKey]
+	if !ok {
+		return nil, fmt.Errorf("reclaim escrow shares metadata not specified")
+	}
+	sharesStr, ok := sharesRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("malformed reclaim escrow shares metadata")
+	}
+	var shares quantity.Quantity
+	if err := shares.UnmarshalText([]byte(sharesStr)); err != nil {
+		return nil, fmt.Errorf("malformed reclaim escrow shares metadata (%s): %w", sharesStr, err)
+	}
+	return &shares, nil
+}
+
+// reclaimEscrowShares converts the amount to reclaim from the given escrow
+// account to shares using the escrow pool at the latest height.
+//
+// The shares are rounded up so that at least the given amount is reclaimed,
+// but they are capped at the shares delegated by the owner.
+func (s *constructionAPIService) reclaimEscrowShares(
+	ctx context.Context,
+	owner staking.Address,
+	accountRaw interface{},
+	amountRaw interface{},
+) (*quantity.Quantity, *types.Error) {
+	accountStr, ok := accountRaw.(string)
+	if !ok {
+		loggerCons.Error("ConstructionMetadata: malformed reclaim escrow account field")
+		return nil, ErrInvalidAccountAddress
+	}
+	var account staking.Address
+	if err := account.UnmarshalText([]byte(accountStr)); err != nil {
+		loggerCons.Error("ConstructionMetadata: invalid reclaim escrow account", "err", err)
+		return nil, ErrInvalidAccountAddress
+	}
+	amountStr, ok := amountRaw.(string)
+	if !ok {
+		loggerCons.Error("ConstructionMetadata: malformed reclaim escrow amount field")
+		return nil, ErrMalformedValue
+	}
+	var amount quantity.Quantity
+	if err := amount.UnmarshalText([]byte(amountStr)); err != nil {
+		loggerCons.Error("ConstructionMetadata: malformed reclaim escrow amount", "err", err)
+		return nil, ErrMalformedValue
+	}
+
+	// Use the same height for the escrow pool and the delegation.
+	blk, err := s.oasisClient.GetLatestBlock(ctx)
+	if err != nil {
+		loggerCons.Error("ConstructionMetadata: unable to get latest block", "err", err)
+		return nil, ErrUnableToGetLatestBlk
+	}
+	act, err := s.oasisClient.GetAccount(ctx, blk.Height, account)
+	if err != nil {
+		loggerCons.Error("ConstructionMetadata: unable to get reclaim escrow account",
+			"account_id", account.String(),
+			"height", blk.Height,
+			"err", err,
+		)
+		return nil, ErrUnableToGetAccount
+	}
+	delegations, err := s.oasisClient.GetDelegations(ctx, blk.Height, owner)
+	if err != nil {
+		loggerCons.Error("ConstructionMetadata: unable to get delegations",
+			"account_id", owner.String(),
+			"height", blk.Height,
+			"err", err,
+		)
+		return nil, ErrUnableToGetAccount
+	}
+	delegation, ok := delegations[account]
+	if !ok || act.Escrow.Active.Balance.IsZero() {
+		err = fmt.Errorf("no active delegation to %s", account.String())
+		loggerCons.Error("ConstructionMetadata: unable to reclaim escrow",
+			"account_id", owner.String(),
+			"err", err,
+		)
+		return nil, NewDetailedError(ErrMalformedValue, err)
+	}
+
+	// shares = ceil(amount * total_shares / balance)
+	pool := act.Escrow.Active
+	balance := pool.Balance.ToBigInt()
+	sharesBI := new(big.Int).Mul(amount.ToBigInt(), pool.TotalShares.ToBigInt())
+	sharesBI.Add(sharesBI, balance)
+	sharesBI.Sub(sharesBI, big.NewInt(1))
+	sharesBI.Quo(sharesBI, balance)
+
+	var shares quantity.Quantity
+	if err = shares.FromBigInt(sharesBI); err != nil {
+		loggerCons.Error("ConstructionMetadata: unable to compute reclaim escrow shares", "err", err)
+		return nil, NewDetailedError(ErrMalformedValue, err)
+	}
+	if shares.Cmp(&delegation.Shares) > 0 {
+		shares = *delegation.Shares.Clone()
+	}
+	return &shares, nil
+}
+
+// withReclaimEscrowShares returns the given Base64 encoded CBOR reclaim escrow
+// transaction with its shares replaced by the given shares.
+func withReclaimEscrowShares(txRaw interface{}, shares *quantity.Quantity) (interface{}, error) {
+	txStr, ok := txRaw.(string)
+	if !ok {
+		return nil, fmt.Errorf("malformed transaction field")
+	}
+	rawTx, err := base64.StdEncoding.DecodeString(txStr)
+	if err != nil {
+		return nil, fmt.Errorf("malformed transaction field: %w", err)
+	}
+	var tx transaction.Transaction
+	if err = cbor.Unmarshal(rawTx, &tx); err != nil {
+		return nil, fmt.Errorf("malformed transaction: %w", err)
+	}
+	if tx.Method != staking.MethodReclaimEscrow {
+		return nil, fmt.Errorf("not a reclaim escrow transaction")
+	}
+	var reclaim staking.ReclaimEscrow
+	if err = cbor.Unmarshal(tx.Body, &reclaim); err != nil {
+		return nil, fmt.Errorf("malformed body: %w", err)
+	}
+	reclaim.Shares = *shares
+	tx.Body = cbor.Marshal(reclaim)
+	return base64.StdEncoding.EncodeToString(cbor.Marshal(tx)), nil
+}
+
+// ReclaimEscrowEstimate is the estimated amount that the shares reclaimed by
+// a reclaim escrow operation are worth.
+type ReclaimEscrowEstimate struct {
+	// OperationIndex is the index of the escrow account's reclaim escrow
+	// operation.
+	OperationIndex int64 `json:"operation_index"`
+	// Amount is the estimated amount in base units.
+	Amount string `json:"amount"`
+}
+
+// reclaimEscrowEstimates returns the estimated amounts that the shares
+// reclaimed by the given reclaim escrow operations are worth at the latest
+// height.  The estimates are informational, so operations whose amount can't
+// be estimated are skipped.
+func (s *constructionAPIService) reclaimEscrowEstimates(
+	ctx context.Context,
+	ops []*types.Operation,
+) []ReclaimEscrowEstimate {
+	var estimates []ReclaimEscrowEstimate
+	for _, op := range ops {
+		if op.Type != OpReclaimEscrow || op.Account.SubAccount == nil {
+			continue
+		}
+		sharesStr, ok := op.Metadata[ReclaimEscrowSharesKey].(string)
+		if !ok {
+			continue
+		}
+		var shares quantity.Quantity
+		if err := shares.UnmarshalText([]byte(sharesStr)); err != nil {
+			loggerCons.Warn("ConstructionParse: malformed reclaim escrow shares", "err", err)
+			continue
+		}
+		var account staking.Address
+		if err := account.UnmarshalText([]byte(op.Account.Address)); err != nil {
+			loggerCons.Warn("ConstructionParse: invalid reclaim escrow account", "err", err)
+			continue
+		}
+		act, err := s.oasisClient.GetAccount(ctx, oasis.LatestHeight, account)
+		if err != nil {
+			loggerCons.Warn("ConstructionParse: unable to get reclaim escrow account",
+				"account_id", account.String(),
+				"err", err,
+			)
+			continue
+		}
+		amount, err := act.Escrow.Active.StakeForShares(&shares)
+		if err != nil {
+			loggerCons.Warn("ConstructionParse: unable to estimate reclaim escrow amount", "err", err)
+			continue
+		}
+		estimates = append(estimates, ReclaimEscrowEstimate{
+			OperationIndex: op.OperationIdentifier.Index,
+			Amount:         amount.String(),
+		})
+	}
+	return estimates
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
+)
+
+const (
+	// DefaultSearchLimit is the number of transactions returned by the
+	// /search/transactions endpoint if no limit is given.
+	DefaultSearchLimit = 100
+	// MaxSearchLimit is the maximum number of transactions returned by the
+	// /search/transactions endpoint.
+	MaxSearchLimit = 1000
+	// DefaultSearchMaxTotalCount is the default number of matching
+	// transactions above which the /search/transactions endpoint stops
+	// counting once it has found the requested page.  The reported total count
+	// is then only a lower bound.
+	DefaultSearchMaxTotalCount = 1000
+)
+
+// SearchMaxTotalCountEnvVar is the name of the environment variable that
+// overrides DefaultSearchMaxTotalCount.
+const SearchMaxTotalCountEnvVar = "OASIS_ROSETTA_GATEWAY_SEARCH_MAX_TOTAL_COUNT"
+
+var loggerSearch = logging.GetLogger("services/search")
+
+type searchAPIService struct {
+	oasisClient   oasis.Client
+	store         *storage.Store
+	maxTotalCount int64
+}
+
+// NewSearchAPIService creates a new instance of a SearchAPIService.
+// The service only works if the transaction indexer is running.
+// If maxTotalCount is zero, DefaultSearchMaxTotalCount is used.
+func NewSearchAPIService(oasisClient oasis.Client, store *storage.Store, maxTotalCount int64) server.SearchAPIServicer {
+	if maxTotalCount == 0 {
+		maxTotalCount = DefaultSearchMaxTotalCount
+	}
+	return &searchAPIService{
+		oasisClient:   oasisClient,
+		store:         store,
+		maxTotalCount: maxTotalCount,
+	}
+}
+
+// txFilter is a single search condition.
+type txFilter func(*storage.IndexedTransaction) bool
+
+// anyOp returns a filter that matches transactions with at least one
+// operation matching the given predicate.
+func anyOp(pred func(*types.Operation) bool) txFilter {
+	return func(tx *storage.IndexedTransaction) bool {
+		for _, op := range tx.Transaction.Operations {
+			if pred(op) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isMethodName returns true if the given search type is a transaction method
+// name (e.g., staking.Transfer) rather than an operation type.
+func isMethodName(typ string) bool {
+	return strings.Contains(typ, ".")
+}
+
+// searchFilters converts the conditions in the given request into filters.
+func searchFilters(request *types.SearchTransactionsRequest) []txFilter {
+	var filters []txFilter
+
+	if ti := request.TransactionIdentifier; ti != nil {
+		filters = append(filters, func(tx *storage.IndexedTransaction) bool {
+			return strings.EqualFold(tx.Transaction.TransactionIdentifier.Hash, ti.Hash)
+		})
+	}
+	if ai := request.AccountIdentifier; ai != nil {
+		filters = append(filters, anyOp(func(op *types.Operation) bool {
+			return types.Hash(op.Account) == types.Hash(ai)
+		}))
+	}
+	if addr := request.Address; addr != nil {
+		opFilter := anyOp(func(op *types.Operation) bool {
+			return op.Account.Address == *addr
+		})
+		filters = append(filters, func(tx *storage.IndexedTransaction) bool {
+			return tx.Signer == *addr || opFilter(tx)
+		})
+	}
+	if request.CoinIdentifier != nil {
+		// Coins are not supported, so nothing can match.
+		filters = append(filters, func(*storage.IndexedTransaction) bool {
+			return false
+		})
+	}
+	if cur := request.Currency; cur != nil {
+		filters = append(filters, anyOp(func(op *types.Operation) bool {
+			return op.Amount != nil && types.Hash(op.Amount.Currency) == types.Hash(cur)
+		}))
+	}
+	if status := request.Status; status != nil {
+		filters = append(filters, anyOp(func(op *types.Operation) bool {
+			return op.Status != nil && *op.Status == *status
+		}))
+	}
+	if typ := request.Type; typ != nil {
+		switch isMethodName(*typ) {
+		case true:
+			filters = append(filters, func(tx *storage.IndexedTransaction) bool {
+				return tx.Method == *typ
+			})
+		case false:
+			filters = append(filters, anyOp(func(op *types.Operation) bool {
+				return op.Type == *typ
+			}))
+		}
+	}
+	if success := request.Success; success != nil {
+		// Failed transactions have the error in their metadata, even though
+		// their fee operations succeeded.
+		filters = append(filters, func(tx *storage.IndexedTransaction) bool {
+			_, failed := tx.Transaction.Metadata[TxErrorKey]
+			return !failed == *success
+		})
+	}
+
+	return filters
+}
+
+// SearchTransactions implements the /search/transactions endpoint.
+func (s *searchAPIService) SearchTransactions(
+	ctx context.Context,
+	request *types.SearchTransactionsRequest,
+) (*types.SearchTransactionsResponse, *types.Error) {
+	terr := ValidateNetworkIdentifier(ctx, s.oasisClient, request.NetworkIdentifier)
+	if terr != nil {
+		loggerSearch.Error("SearchTransactions: network validation failed", "err", terr.Message)
+		return nil, terr
+	}
+
+	filters := searchFilters(request)
+
+	maxHeight, ok, err := s.store.GetIndexedHeight()
+	if err != nil {
+		loggerSearch.Error("SearchTransactions: unable to get indexed height", "err", err)
+		return nil, ErrUnableToGetTxns
+	}
+	if !ok {
+		return &types.SearchTransactionsResponse{
+			Transactions: []*types.BlockTransaction{},
+		}, nil
+	}
+	if request.MaxBlock != nil && *request.MaxBlock < maxHeight {
+		maxHeight = *request.MaxBlock
+	}
+
+	offset := int64(0)
+	if request.Offset != nil {
+		offset = *request.Offset
+	}
+	limit := int64(DefaultSearchLimit)
+	if request.Limit != nil {
+		limit = *request.Limit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	or := request.Operator != nil && *request.Operator == types.OR
+	matches := func(tx *storage.IndexedTransaction) bool {
+		if len(filters) == 0 {
+			return true
+		}
+		for _, f := range filters {
+			if f(tx) == or {
+				return or
+			}
+		}
+		return !or
+	}
+
+	// Stop counting matches once the page is complete and the count reaches
+	// the cap, so that a request doesn't always scan the whole index.  Count
+	// at least one match past the page, so that the next page is known to
+	// exist.
+	maxTotal := offset + limit + 1
+	if maxTotal < s.maxTotalCount {
+		maxTotal = s.maxTotalCount
+	}
+
+	txs := []*types.BlockTransaction{}
+	var total int64
+	collect := func(tx *storage.IndexedTransaction) bool {
+		if !matches(tx) {
+			return true
+		}
+		if total >= offset && total < offset+limit {
+			txs = append(txs, &types.BlockTransaction{
+				BlockIdentifier: &types.BlockIdentifier{
+					Index: tx.Height,
+					Hash:  tx.BlockHash,
+				},
+				Transaction: tx.Transaction,
+			})
+		}
+		total++
+		return total < maxTotal
+	}
+
+	// With the AND operator, use the most selective secondary index available.
+	switch {
+	case !or && request.TransactionIdentifier != nil:
+		var txHash hash.Hash
+		if err = txHash.UnmarshalHex(request.TransactionIdentifier.Hash); err != nil {
+			loggerSearch.Error("SearchTransactions: malformed transaction hash", "err", err)
+			return nil, ErrMalformedValue
+		}
+		var tx *storage.IndexedTransaction
+		if tx, err = s.store.GetTransaction(txHash); err == nil && tx != nil && tx.Height <= maxHeight {
+			collect(tx)
+		}
+	case !or && (request.Address != nil || request.AccountIdentifier != nil):
+		var addrStr string
+		switch {
+		case request.Address != nil:
+			addrStr = *request.Address
+		default:
+			addrStr = request.AccountIdentifier.Address
+		}
+		var addr staking.Address
+		if err = addr.UnmarshalText([]byte(addrStr)); err != nil {
+			loggerSearch.Error("SearchTransactions: invalid account address", "err", err)
+			return nil, ErrInvalidAccountAddress
+		}
+		err = s.store.ForEachAccountTransaction(addr, maxHeight, collect)
+	case !or && request.Type != nil && isMethodName(*request.Type):
+		err = s.store.ForEachMethodTransaction(*request.Type, maxHeight, collect)
+	default:
+		err = s.store.ForEachTransaction(maxHeight, collect)
+	}
+	if err != nil {
+		loggerSearch.Error("SearchTransactions: unable to query transaction index", "err", err)
+		return nil, ErrUnableToGetTxns
+	}
+
+	resp := &types.SearchTransactionsResponse{
+		Transactions: txs,
+		TotalCount:   total,
+	}
+	if nextOffset := offset + int64(len(txs)); nextOffset < total {
+		resp.NextOffset = &nextOffset
+	}
+
+	jr, _ := json.Marshal(resp)
+	loggerSearch.Debug("SearchTransactions OK", "response", jr)
+
+	return resp, nil
+}
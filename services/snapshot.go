@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+)
+
+// mempoolRefreshInterval is the time between refreshes of the mempool
+// snapshot.
+const mempoolRefreshInterval = 1 * time.Second
+
+// mempoolMaxAge is the age above which the mempool snapshot is refreshed when
+// it is accessed, e.g. because the periodic refresh failed.
+const mempoolMaxAge = 5 * time.Second
+
+// mempoolIdleTimeout is the time after the last access of the mempool
+// snapshot after which it is no longer refreshed periodically.
+const mempoolIdleTimeout = 1 * time.Minute
+
+// mempoolEntry is a transaction in a mempool snapshot.
+type mempoolEntry struct {
+	hash hash.Hash
+	// sender is the address of the transaction's signer and nonce is the
+	// transaction's nonce, if the signed transaction could be decoded.
+	sender *staking.Address
+	nonce  uint64
+	// tx is the decoded transaction or nil if it couldn't be decoded.  The
+	// other fields are only set if it could.
+	tx *types.Transaction
+	// signer is the address of the transaction's signer.
+	signer string
+	// accounts are the addresses of the signer and of the accounts in the
+	// transaction's operations.
+	accounts map[string]bool
+}
+
+// mempoolSnapshot is a decoded snapshot of the node's mempool.
+type mempoolSnapshot struct {
+	takenAt time.Time
+
+	// entries are the transactions in the order returned by the node.
+	entries   []*mempoolEntry
+	byHash    map[hash.Hash]*mempoolEntry
+	bySigner  map[string][]*mempoolEntry
+	byAccount map[string][]*mempoolEntry
+	// nonces maps signers to the nonces of their transactions.
+	nonces map[staking.Address]map[uint64]bool
+}
+
+// Mempool maintains a decoded snapshot of the node's mempool, indexed by
+// transaction hash, by signer and by affected account.  It is shared by the
+// mempool endpoints, the nonce checks and the transaction tracker, so that the
+// node's mempool is only polled once.
+type Mempool struct {
+	oasisClient oasis.Client
+
+	// refreshMu serializes refreshes of the snapshot.
+	refreshMu sync.Mutex
+
+	mu      sync.RWMutex
+	current *mempoolSnapshot
+	// lastUsed is the time of the last access of the snapshot.
+	lastUsed time.Time
+}
+
+// NewMempool creates a new mempool snapshot maintainer.
+func NewMempool(oasisClient oasis.Client) *Mempool {
+	return &Mempool{
+		oasisClient: oasisClient,
+	}
+}
+
+// Run refreshes the mempool snapshot periodically until the given context is
+// canceled.  The snapshot is only refreshed while it is in use, i.e. if it has
+// been accessed within mempoolIdleTimeout, so the node's mempool isn't polled
+// until the first access.
+func (m *Mempool) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(mempoolRefreshInterval):
+		}
+
+		m.mu.RLock()
+		idle := time.Since(m.lastUsed) >= mempoolIdleTimeout
+		m.mu.RUnlock()
+		if idle {
+			continue
+		}
+		if _, err := m.refresh(ctx); err != nil {
+			loggerMempool.Error("failed to refresh mempool snapshot", "err", err)
+		}
+	}
+}
+
+// snapshot returns the current mempool snapshot, refreshing it if it is
+// missing or too old.
+func (m *Mempool) snapshot(ctx context.Context) (*mempoolSnapshot, error) {
+	m.mu.Lock()
+	snap := m.current
+	m.lastUsed = time.Now()
+	m.mu.Unlock()
+	if snap != nil && time.Since(snap.takenAt) < mempoolMaxAge {
+		return snap, nil
+	}
+	return m.refresh(ctx)
+}
+
+// signerNonces returns the nonces of the given signer's transactions in the
+// mempool.  If fresh is set, a new snapshot is taken instead of using the
+// current one.
+func (m *Mempool) signerNonces(ctx context.Context, signer staking.Address, fresh bool) (map[uint64]bool, error) {
+	var snap *mempoolSnapshot
+	var err error
+	switch fresh {
+	case true:
+		m.mu.Lock()
+		m.lastUsed = time.Now()
+		m.mu.Unlock()
+		snap, err = m.refresh(ctx)
+	case false:
+		snap, err = m.snapshot(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snap.nonces[signer], nil
+}
+
+// refresh takes a new mempool snapshot.  Transactions that are already in the
+// current snapshot aren't decoded again.
+func (m *Mempool) refresh(ctx context.Context) (*mempoolSnapshot, error) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	takenAt := time.Now()
+	rawTxs, err := m.oasisClient.GetUnconfirmedTransactions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get unconfirmed transactions: %w", err)
+	}
+
+	m.mu.RLock()
+	prev := m.current
+	m.mu.RUnlock()
+
+	snap := &mempoolSnapshot{
+		takenAt:   takenAt,
+		entries:   make([]*mempoolEntry, 0, len(rawTxs)),
+		byHash:    make(map[hash.Hash]*mempoolEntry, len(rawTxs)),
+		bySigner:  make(map[string][]*mempoolEntry),
+		byAccount: make(map[string][]*mempoolEntry),
+		nonces:    make(map[staking.Address]map[uint64]bool),
+	}
+	for _, rawTx := range rawTxs {
+		txHash := hash.NewFromBytes(rawTx)
+		if _, ok := snap.byHash[txHash]; ok {
+			continue
+		}
+		var entry *mempoolEntry
+		if prev != nil {
+			entry = prev.byHash[txHash]
+		}
+		if entry == nil {
+			entry = newMempoolEntry(txHash, rawTx)
+		}
+
+		snap.entries = append(snap.entries, entry)
+		snap.byHash[txHash] = entry
+		if entry.tx != nil {
+			snap.bySigner[entry.signer] = append(snap.bySigner[entry.signer], entry)
+		}
+		for addr := range entry.accounts {
+			snap.byAccount[addr] = append(snap.byAccount[addr], entry)
+		}
+		if entry.sender != nil {
+			if snap.nonces[*entry.sender] == nil {
+				snap.nonces[*entry.sender] = make(map[uint64]bool)
+			}
+			snap.nonces[*entry.sender][entry.nonce] = true
+		}
+	}
+
+	m.mu.Lock()
+	m.current = snap
+	m.mu.Unlock()
+
+	return snap, nil
+}
+
+// newMempoolEntry decodes the given raw transaction with the given hash.
+// Transactions that can't be decoded are only identified by their hash.
+func newMempoolEntry(txHash hash.Hash, rawTx []byte) *mempoolEntry {
+	entry := &mempoolEntry{
+		hash: txHash,
+	}
+
+	var sigTx transaction.SignedTransaction
+	if err := cbor.Unmarshal(rawTx, &sigTx); err != nil {
+		loggerMempool.Debug("unable to decode unconfirmed transaction",
+			"tx_hash", txHash.String(),
+			"err", err,
+		)
+		return entry
+	}
+	var tx transaction.Transaction
+	if err := cbor.Unmarshal(sigTx.Blob, &tx); err == nil {
+		sender := staking.NewAddress(sigTx.Signature.PublicKey)
+		entry.sender = &sender
+		entry.nonce = tx.Nonce
+	}
+
+	td := newTransactionsDecoder()
+	if err := td.DecodeTx(rawTx, nil); err != nil {
+		loggerMempool.Debug("unable to decode unconfirmed transaction",
+			"tx_hash", txHash.String(),
+			"err", err,
+		)
+		return entry
+	}
+	entry.tx = td.Transaction(txHash)
+	entry.signer = StringFromAddress(staking.NewAddress(sigTx.Signature.PublicKey))
+
+	entry.accounts = map[string]bool{
+		entry.signer: true,
+	}
+	for _, op := range entry.tx.Operations {
+		if op.Account != nil {
+			entry.accounts[op.Account.Address] = true
+		}
+	}
+	return entry
+}
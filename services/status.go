@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction/results"
+)
+
+// ConstructionStatusRequest is the request of the /construction/status
+// endpoint.
+type ConstructionStatusRequest struct {
+	NetworkIdentifier     *types.NetworkIdentifier     `json:"network_identifier"`
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+}
+
+// ConstructionStatusResponse is the response of the /construction/status
+// endpoint.
+type ConstructionStatusResponse struct {
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	// State is the lifecycle state of the transaction, one of TxStatePending,
+	// TxStateIncluded, TxStateFailed, TxStateDropped and TxStateExpired.
+	State string `json:"state"`
+	// SubmittedTimestamp is the UNIX time in milliseconds of the last
+	// submission of the transaction through the gateway, including
+	// rebroadcasts.
+	SubmittedTimestamp int64 `json:"submitted_timestamp"`
+	// Rebroadcasts is the number of times the transaction was rebroadcast
+	// since it was last submitted by a client.
+	Rebroadcasts int `json:"rebroadcasts"`
+	// BlockIdentifier identifies the block that includes the transaction, if
+	// any.
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier,omitempty"`
+	// Error is the error of a failed transaction, with the keys ModuleKey,
+	// CodeKey and MsgKey.
+	Error map[string]interface{} `json:"error,omitempty"`
+}
+
+// ConstructionStatus implements the /construction/status endpoint.
+func (s *extensionsAPIService) ConstructionStatus(
+	ctx context.Context,
+	request *ConstructionStatusRequest,
+) (*ConstructionStatusResponse, *types.Error) {
+	if s.construction.tracker == nil {
+		loggerExt.Error("ConstructionStatus: transaction tracker not enabled")
+		return nil, ErrNotImplemented
+	}
+
+	terr := ValidateNetworkIdentifier(ctx, s.oasisClient, request.NetworkIdentifier)
+	if terr != nil {
+		loggerExt.Error("ConstructionStatus: network validation failed", "err", terr.Message)
+		return nil, terr
+	}
+
+	if request.TransactionIdentifier == nil {
+		loggerExt.Error("ConstructionStatus: missing transaction identifier")
+		return nil, ErrMalformedValue
+	}
+	var txHash hash.Hash
+	if err := txHash.UnmarshalHex(request.TransactionIdentifier.Hash); err != nil {
+		loggerExt.Error("ConstructionStatus: malformed transaction hash",
+			"tx_hash", request.TransactionIdentifier.Hash,
+			"err", err,
+		)
+		return nil, ErrMalformedValue
+	}
+
+	ttx, err := s.construction.tracker.Get(txHash)
+	if err != nil {
+		loggerExt.Error("ConstructionStatus: unable to get tracked transaction",
+			"tx_hash", txHash.String(),
+			"err", err,
+		)
+		return nil, NewDetailedError(ErrUnableToGetTrackedTx, err)
+	}
+	if ttx == nil {
+		loggerExt.Error("ConstructionStatus: transaction not tracked", "tx_hash", txHash.String())
+		return nil, ErrTransactionNotTracked
+	}
+
+	resp := &ConstructionStatusResponse{
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: ttx.Hash,
+		},
+		State:              ttx.State,
+		SubmittedTimestamp: ttx.SubmittedAt,
+		Rebroadcasts:       ttx.Rebroadcasts,
+	}
+	if ttx.BlockHash != "" {
+		resp.BlockIdentifier = &types.BlockIdentifier{
+			Index: ttx.Height,
+			Hash:  ttx.BlockHash,
+		}
+	}
+	if ttx.Error != nil {
+		resp.Error = resultError(&results.Result{Error: *ttx.Error})
+	}
+
+	jr, _ := json.Marshal(resp)
+	loggerExt.Debug("ConstructionStatus OK", "response", jr)
+
+	return resp, nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+)
+
+const (
+	// DefaultSubmitWaitTimeout is the time that the
+	// /construction/submit_and_wait endpoint waits for the transaction to be
+	// included in a block if no timeout is given.
+	DefaultSubmitWaitTimeout = 60 * time.Second
+	// MaxSubmitWaitTimeout is the maximum time that the
+	// /construction/submit_and_wait endpoint waits for the transaction to be
+	// included in a block.
+	MaxSubmitWaitTimeout = 10 * time.Minute
+)
+
+// submitWaitLookup is the number of blocks after the submission height in
+// which /construction/submit_and_wait looks for a transaction whose
+// submission failed, since the node also reports execution failures as
+// submission errors.
+const submitWaitLookup = 10
+
+// ConstructionSubmitAndWaitRequest is the request of the
+// /construction/submit_and_wait endpoint.
+type ConstructionSubmitAndWaitRequest struct {
+	NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	SignedTransaction string                   `json:"signed_transaction"`
+	// TimeoutSeconds is the time to wait for the transaction to be included
+	// in a block.  DefaultSubmitWaitTimeout is used if it is absent.
+	TimeoutSeconds *int64 `json:"timeout_seconds,omitempty"`
+}
+
+// ConstructionSubmitAndWaitResponse is the response of the
+// /construction/submit_and_wait endpoint.
+type ConstructionSubmitAndWaitResponse struct {
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier"`
+	// BlockIdentifier identifies the block that includes the transaction.
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	// Success is whether the transaction was executed successfully.
+	Success bool `json:"success"`
+	// Error is the error of a failed transaction, with the keys ModuleKey,
+	// CodeKey and MsgKey.
+	Error map[string]interface{} `json:"error,omitempty"`
+}
+
+// ConstructionSubmitAndWait implements the /construction/submit_and_wait
+// endpoint.
+func (s *extensionsAPIService) ConstructionSubmitAndWait(
+	ctx context.Context,
+	request *ConstructionSubmitAndWaitRequest,
+) (*ConstructionSubmitAndWaitResponse, *types.Error) {
+	terr := ValidateNetworkIdentifier(ctx, s.oasisClient, request.NetworkIdentifier)
+	if terr != nil {
+		loggerExt.Error("ConstructionSubmitAndWait: network validation failed", "err", terr.Message)
+		return nil, terr
+	}
+
+	tx, err := DecodeSignedTransaction(request.SignedTransaction)
+	if err != nil {
+		loggerExt.Error("ConstructionSubmitAndWait: failed to unmarshal signed transaction",
+			"err", err,
+			"signed_tx", request.SignedTransaction,
+		)
+		return nil, ErrMalformedValue
+	}
+	txHash := tx.Hash()
+
+	timeout := DefaultSubmitWaitTimeout
+	if request.TimeoutSeconds != nil {
+		if *request.TimeoutSeconds <= 0 {
+			loggerExt.Error("ConstructionSubmitAndWait: invalid timeout",
+				"timeout_seconds", *request.TimeoutSeconds,
+			)
+			return nil, ErrMalformedValue
+		}
+		if *request.TimeoutSeconds < int64(MaxSubmitWaitTimeout/time.Second) {
+			timeout = time.Duration(*request.TimeoutSeconds) * time.Second
+		} else {
+			timeout = MaxSubmitWaitTimeout
+		}
+	}
+
+	if s.construction.submitPreflight {
+		if terr = s.construction.preflight(ctx, []*transaction.SignedTransaction{tx}); terr != nil {
+			return nil, terr
+		}
+	}
+
+	// The node reports an error for transactions whose execution failed, so
+	// those are looked up in the blocks after the submission height.
+	submitBlk, err := s.oasisClient.GetLatestBlock(ctx)
+	if err != nil {
+		loggerExt.Error("ConstructionSubmitAndWait: unable to get latest block", "err", err)
+		return nil, ErrUnableToGetLatestBlk
+	}
+
+	trackHeight := s.construction.trackHeight(ctx)
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	proof, err := s.oasisClient.SubmitTxWithProof(waitCtx, tx)
+	var resp *ConstructionSubmitAndWaitResponse
+	switch {
+	case err == nil:
+		if resp, terr = s.includedTx(ctx, tx, proof.Height); terr != nil {
+			return nil, terr
+		}
+		if resp == nil {
+			loggerExt.Error("ConstructionSubmitAndWait: transaction not found in block",
+				"height", proof.Height,
+				"tx_hash", txHash.String(),
+			)
+			return nil, ErrTransactionNotFound
+		}
+	case waitCtx.Err() != nil:
+		// The transaction may still be included later.
+		loggerExt.Error("ConstructionSubmitAndWait: timed out waiting for inclusion",
+			"tx_hash", txHash.String(),
+			"err", err,
+		)
+		s.construction.track(tx, trackHeight)
+		terr = NewDetailedError(ErrSubmitTimeout, err)
+		terr.Details[TxHashesKey] = []string{txHash.String()}
+		return nil, terr
+	case errors.Is(err, consensus.ErrDuplicateTx):
+		loggerExt.Error("ConstructionSubmitAndWait: SubmitTxWithProof failed", "err", err)
+		loggerExt.Info("ConstructionSubmitAndWait: transaction already in the mempool, can't wait for it")
+		s.construction.track(tx, trackHeight)
+		return nil, NewDetailedError(ErrUnableToSubmitTx, err)
+	default:
+		latestBlk, err2 := s.oasisClient.GetLatestBlock(ctx)
+		if err2 != nil {
+			loggerExt.Error("ConstructionSubmitAndWait: unable to get latest block", "err", err2)
+			return nil, ErrUnableToGetLatestBlk
+		}
+		to := latestBlk.Height
+		if to > submitBlk.Height+submitWaitLookup {
+			to = submitBlk.Height + submitWaitLookup
+		}
+		for height := submitBlk.Height + 1; height <= to && resp == nil; height++ {
+			if resp, terr = s.includedTx(ctx, tx, height); terr != nil {
+				return nil, terr
+			}
+		}
+		if resp == nil {
+			loggerExt.Error("ConstructionSubmitAndWait: SubmitTxWithProof failed", "err", err)
+			return nil, NewDetailedError(ErrUnableToSubmitTx, err)
+		}
+	}
+
+	jr, _ := json.Marshal(resp)
+	loggerExt.Debug("ConstructionSubmitAndWait OK", "response", jr)
+
+	return resp, nil
+}
+
+// includedTx returns the response for the given transaction if it is included
+// in the block at the given height, or nil if it isn't.
+func (s *extensionsAPIService) includedTx(
+	ctx context.Context,
+	tx *transaction.SignedTransaction,
+	height int64,
+) (*ConstructionSubmitAndWaitResponse, *types.Error) {
+	blk, err := s.oasisClient.GetBlock(ctx, height)
+	if err != nil {
+		loggerExt.Error("ConstructionSubmitAndWait: unable to get block",
+			"height", height,
+			"err", err,
+		)
+		return nil, ErrUnableToGetBlk
+	}
+	txsWithRes, err := s.oasisClient.GetTransactionsWithResults(ctx, height)
+	if err != nil {
+		loggerExt.Error("ConstructionSubmitAndWait: unable to get transactions",
+			"height", height,
+			"err", err,
+		)
+		return nil, ErrUnableToGetTxns
+	}
+	txHash := tx.Hash()
+	for i, rawTx := range txsWithRes.Transactions {
+		if rawTxHash := hash.NewFromBytes(rawTx); !rawTxHash.Equal(&txHash) {
+			continue
+		}
+		result := txsWithRes.Results[i]
+		resp := &ConstructionSubmitAndWaitResponse{
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: txHash.String(),
+			},
+			BlockIdentifier: &types.BlockIdentifier{
+				Index: blk.Height,
+				Hash:  blk.Hash,
+			},
+			Success: result.IsSuccess(),
+		}
+		if !result.IsSuccess() {
+			resp.Error = resultError(result)
+		}
+		if s.construction.tracker != nil {
+			if err = s.construction.tracker.TrackIncluded(tx, blk, result); err != nil {
+				loggerExt.Error("ConstructionSubmitAndWait: failed to track transaction",
+					"tx_hash", txHash.String(),
+					"err", err,
+				)
+			}
+		}
+		return resp, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction/results"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
+)
+
+// TrackerEnvVar is the name of the environment variable that enables tracking
+// the lifecycle of transactions submitted through the gateway (and the
+// /construction/status endpoint) when set to a non-empty value.
+const TrackerEnvVar = "OASIS_ROSETTA_GATEWAY_TRACKER"
+
+// TrackerRetentionEnvVar is the name of the environment variable that
+// specifies for how many seconds the transaction tracker keeps transactions
+// that reached a final state (included, failed or expired).
+// If unset or zero, they are kept for DefaultTrackerRetention.
+const TrackerRetentionEnvVar = "OASIS_ROSETTA_GATEWAY_TRACKER_RETENTION"
+
+// DefaultTrackerRetention is the time for which the transaction tracker keeps
+// transactions that reached a final state by default.
+const DefaultTrackerRetention = 24 * time.Hour
+
+// trackerPollInterval is the time the tracker waits between checks of the
+// mempool and new blocks.
+const trackerPollInterval = 1 * time.Second
+
+// trackerMaxPruned is the maximum number of transactions the tracker deletes
+// at once.
+const trackerMaxPruned = 1000
+
+// Lifecycle states of tracked transactions.
+const (
+	// TxStatePending is the state of a transaction that is waiting in the
+	// mempool.
+	TxStatePending = "pending"
+	// TxStateIncluded is the state of a transaction that was included in a
+	// block and executed successfully.
+	TxStateIncluded = "included"
+	// TxStateFailed is the state of a transaction that was included in a
+	// block, but whose execution failed.
+	TxStateFailed = "failed"
+	// TxStateDropped is the state of a transaction that disappeared from the
+	// mempool without being included in a block.
+	TxStateDropped = "dropped"
+	// TxStateExpired is the state of a transaction that can no longer be
+	// included in a block, since its signer's nonce has moved past it.
+	TxStateExpired = "expired"
+)
+
+var loggerTrk = logging.GetLogger("services/tracker")
+
+// Tracker follows the lifecycle of transactions submitted through the gateway
+// by watching the mempool and new blocks.
+type Tracker struct {
+	oasisClient        oasis.Client
+	store              *storage.Store
+	mempool            *Mempool
+	rebroadcastRetries int
+	retention          time.Duration
+
+	// mu serializes updates of tracked transactions.
+	mu sync.Mutex
+	// latestHeight is the latest height seen by the tracker.
+	latestHeight int64
+	// fresh maps the transactions tracked since the last update to their
+	// submission heights.  They may be included in blocks that were checked
+	// before they were tracked.
+	fresh map[hash.Hash]int64
+
+	nextHeight int64
+	// droppedCheckedAt maps the hashes of dropped transactions whose
+	// rebroadcast budget is used up to the height at which they were last
+	// checked.
+	droppedCheckedAt map[hash.Hash]int64
+}
+
+// NewTracker creates a new transaction tracker, which watches the given
+// mempool snapshot.  Transactions that disappear from the mempool are
+// resubmitted up to rebroadcastRetries times.
+// Transactions that reached a final state are deleted after the given
+// retention time (or DefaultTrackerRetention if it is zero).
+func NewTracker(
+	oasisClient oasis.Client,
+	store *storage.Store,
+	mempool *Mempool,
+	rebroadcastRetries int,
+	retention time.Duration,
+) *Tracker {
+	if retention == 0 {
+		retention = DefaultTrackerRetention
+	}
+	return &Tracker{
+		oasisClient:        oasisClient,
+		store:              store,
+		mempool:            mempool,
+		rebroadcastRetries: rebroadcastRetries,
+		retention:          retention,
+		fresh:              make(map[hash.Hash]int64),
+		droppedCheckedAt:   make(map[hash.Hash]int64),
+	}
+}
+
+// Height returns a height that is known to exist, so that the blocks up to it
+// can't include transactions submitted afterwards.  It is the latest height
+// seen by the tracker, or the node's latest height if the tracker hasn't seen
+// any yet.
+func (t *Tracker) Height(ctx context.Context) (int64, error) {
+	t.mu.Lock()
+	height := t.latestHeight
+	t.mu.Unlock()
+	if height != 0 {
+		return height, nil
+	}
+
+	status, err := t.oasisClient.GetStatus(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get node status: %w", err)
+	}
+	return status.Consensus.LatestHeight, nil
+}
+
+// Track starts tracking the given transaction, which has just been submitted.
+// The height must have been obtained from Height before the submission.
+// Resubmitting a transaction that was dropped makes it pending again and
+// renews its rebroadcast budget.
+func (t *Tracker) Track(tx *transaction.SignedTransaction, height int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ttx, err := t.store.GetTrackedTransaction(tx.Hash())
+	if err != nil {
+		return err
+	}
+	switch {
+	case ttx == nil:
+		ttx = &storage.TrackedTransaction{
+			Hash:              tx.Hash().String(),
+			SignedTransaction: cbor.Marshal(tx),
+		}
+	case isFinalTxState(ttx.State):
+		return nil
+	}
+	ttx.State = TxStatePending
+	ttx.SubmittedAt = time.Now().UnixMilli()
+	ttx.SubmittedHeight = height
+	ttx.Rebroadcasts = 0
+	if err = t.store.PutTrackedTransaction(ttx); err != nil {
+		return err
+	}
+	t.fresh[tx.Hash()] = height
+	return nil
+}
+
+// TrackIncluded records that the given transaction, which has just been
+// submitted, was included in the given block with the given result.
+func (t *Tracker) TrackIncluded(tx *transaction.SignedTransaction, blk *oasis.Block, result *results.Result) error {
+	if err := t.Track(tx, blk.Height-1); err != nil {
+		return err
+	}
+	return t.setIncluded(tx.Hash(), blk, result)
+}
+
+// Get returns the tracked transaction with the given hash or nil if it isn't
+// tracked.
+func (t *Tracker) Get(txHash hash.Hash) (*storage.TrackedTransaction, error) {
+	return t.store.GetTrackedTransaction(txHash)
+}
+
+// setIncluded marks the tracked transaction with the given hash as included in
+// the given block with the given result.
+func (t *Tracker) setIncluded(txHash hash.Hash, blk *oasis.Block, result *results.Result) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ttx, err := t.store.GetTrackedTransaction(txHash)
+	if err != nil || ttx == nil {
+		return err
+	}
+	ttx.State = TxStateIncluded
+	ttx.Height = blk.Height
+	ttx.BlockHash = blk.Hash
+	ttx.Error = nil
+	ttx.ResolvedAt = time.Now().UnixMilli()
+	if !result.IsSuccess() {
+		ttx.State = TxStateFailed
+		ttx.Error = &result.Error
+	}
+	return t.store.PutTrackedTransaction(ttx)
+}
+
+// setState sets the state of the given tracked transaction unless it has been
+// resubmitted or included since it was read.
+func (t *Tracker) setState(seen *storage.TrackedTransaction, state string) error {
+	return t.modify(seen, func(ttx *storage.TrackedTransaction) {
+		ttx.State = state
+		if isFinalTxState(state) {
+			ttx.ResolvedAt = time.Now().UnixMilli()
+		}
+	})
+}
+
+// isFinalTxState returns true if a tracked transaction in the given state
+// can't change its state anymore.
+func isFinalTxState(state string) bool {
+	return state == TxStateIncluded || state == TxStateFailed || state == TxStateExpired
+}
+
+// modify applies fn to the given tracked transaction unless it has been
+// resubmitted or included since it was read.
+func (t *Tracker) modify(seen *storage.TrackedTransaction, fn func(*storage.TrackedTransaction)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var txHash hash.Hash
+	if err := txHash.UnmarshalHex(seen.Hash); err != nil {
+		return fmt.Errorf("malformed transaction hash: %w", err)
+	}
+	ttx, err := t.store.GetTrackedTransaction(txHash)
+	if err != nil || ttx == nil {
+		return err
+	}
+	if ttx.SubmittedAt != seen.SubmittedAt || ttx.State != seen.State {
+		return nil
+	}
+	fn(ttx)
+	return t.store.PutTrackedTransaction(ttx)
+}
+
+// Run tracks transactions until the given context is canceled.
+func (t *Tracker) Run(ctx context.Context) {
+	for {
+		if err := t.update(ctx); err != nil {
+			loggerTrk.Error("failed to update tracked transactions",
+				"height", t.nextHeight,
+				"err", err,
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(trackerPollInterval):
+		}
+	}
+}
+
+// update checks the mempool and the new blocks for the pending and dropped
+// transactions, handles the ones that disappeared and deletes the ones that
+// reached a final state before the retention time.
+func (t *Tracker) update(ctx context.Context) error {
+	// Take the transactions tracked since the last update before reading the
+	// tracked transactions, so that all of them are read below.
+	t.mu.Lock()
+	fresh := t.fresh
+	t.fresh = make(map[hash.Hash]int64)
+	t.mu.Unlock()
+	rescanned := false
+	defer func() {
+		if rescanned {
+			return
+		}
+		// Check the blocks for the fresh transactions in the next update.
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for txHash, height := range fresh {
+			if _, ok := t.fresh[txHash]; !ok {
+				t.fresh[txHash] = height
+			}
+		}
+	}()
+
+	// Get the mempool snapshot first, so that transactions that leave the
+	// mempool after it was taken are found in the blocks checked below.
+	snap, err := t.mempool.snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get mempool snapshot: %w", err)
+	}
+	snapshotAt := snap.takenAt.UnixMilli()
+
+	status, err := t.oasisClient.GetStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get node status: %w", err)
+	}
+	latestHeight := status.Consensus.LatestHeight
+	t.mu.Lock()
+	t.latestHeight = latestHeight
+	t.mu.Unlock()
+	if t.nextHeight == 0 {
+		height, ok, err2 := t.store.GetTrackedHeight()
+		if err2 != nil {
+			return fmt.Errorf("unable to get tracked height: %w", err2)
+		}
+		t.nextHeight = latestHeight
+		if ok {
+			t.nextHeight = height + 1
+		}
+	}
+	if t.nextHeight < status.Consensus.LastRetainedHeight {
+		t.nextHeight = status.Consensus.LastRetainedHeight
+	}
+
+	unresolved := make(map[hash.Hash]*storage.TrackedTransaction)
+	var expired []hash.Hash
+	expiredBefore := time.Now().Add(-t.retention).UnixMilli()
+	if err = t.store.ForEachTrackedTransaction(func(ttx *storage.TrackedTransaction) bool {
+		var txHash hash.Hash
+		if err2 := txHash.UnmarshalHex(ttx.Hash); err2 != nil {
+			loggerTrk.Warn("malformed tracked transaction hash", "hash", ttx.Hash)
+			return true
+		}
+		switch {
+		case ttx.State == TxStatePending || ttx.State == TxStateDropped:
+			unresolved[txHash] = ttx
+		case isFinalTxState(ttx.State) && len(expired) < trackerMaxPruned:
+			// Transactions tracked before the resolution time was recorded
+			// are kept for the retention time after their submission.
+			resolvedAt := ttx.ResolvedAt
+			if resolvedAt == 0 {
+				resolvedAt = ttx.SubmittedAt
+			}
+			if resolvedAt < expiredBefore {
+				expired = append(expired, txHash)
+			}
+		}
+		return true
+	}); err != nil {
+		return fmt.Errorf("unable to get tracked transactions: %w", err)
+	}
+	if len(expired) > 0 {
+		if err = t.store.DeleteTrackedTransactions(expired); err != nil {
+			return fmt.Errorf("unable to delete tracked transactions: %w", err)
+		}
+		loggerTrk.Debug("deleted resolved transactions", "count", len(expired))
+	}
+
+	// Check the blocks that were checked before the fresh transactions were
+	// tracked again, starting after the earliest submission height.
+	rescan := make(map[hash.Hash]*storage.TrackedTransaction)
+	rescanFrom := t.nextHeight
+	for txHash, height := range fresh {
+		ttx, ok := unresolved[txHash]
+		if !ok || height <= 0 || height+1 >= t.nextHeight {
+			continue
+		}
+		rescan[txHash] = ttx
+		if height+1 < rescanFrom {
+			rescanFrom = height + 1
+		}
+	}
+	if rescanFrom < status.Consensus.LastRetainedHeight {
+		rescanFrom = status.Consensus.LastRetainedHeight
+	}
+	rescanHashes := make([]hash.Hash, 0, len(rescan))
+	for txHash := range rescan {
+		rescanHashes = append(rescanHashes, txHash)
+	}
+	for height := rescanFrom; height < t.nextHeight && len(rescan) > 0; height++ {
+		if err = t.checkBlock(ctx, height, rescan); err != nil {
+			return err
+		}
+	}
+	for _, txHash := range rescanHashes {
+		if _, ok := rescan[txHash]; !ok {
+			delete(unresolved, txHash)
+		}
+	}
+	rescanned = true
+
+	// There's nothing to look for in the new blocks.
+	if len(unresolved) == 0 && t.nextHeight <= latestHeight {
+		t.nextHeight = latestHeight + 1
+		return t.store.PutTrackedHeight(latestHeight)
+	}
+
+	for ; t.nextHeight <= latestHeight; t.nextHeight++ {
+		if err = t.checkBlock(ctx, t.nextHeight, unresolved); err != nil {
+			return err
+		}
+		if err = t.store.PutTrackedHeight(t.nextHeight); err != nil {
+			return fmt.Errorf("unable to put tracked height: %w", err)
+		}
+	}
+
+	for txHash, ttx := range unresolved {
+		switch {
+		case snap.byHash[txHash] != nil:
+			if ttx.State != TxStatePending {
+				err = t.setState(ttx, TxStatePending)
+			}
+		case ttx.SubmittedAt < snapshotAt:
+			err = t.handleMissing(ctx, ttx, latestHeight, status.Consensus.LastRetainedHeight)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to update tracked transaction: %w", err)
+		}
+	}
+	for txHash := range t.droppedCheckedAt {
+		if _, ok := unresolved[txHash]; !ok {
+			delete(t.droppedCheckedAt, txHash)
+		}
+	}
+	return nil
+}
+
+// checkBlock marks the unresolved transactions included in the block at the
+// given height as included and removes them from the given map.
+func (t *Tracker) checkBlock(
+	ctx context.Context,
+	height int64,
+	unresolved map[hash.Hash]*storage.TrackedTransaction,
+) error {
+	txsWithRes, err := t.oasisClient.GetTransactionsWithResults(ctx, height)
+	if err != nil {
+		return fmt.Errorf("unable to get transactions: %w", err)
+	}
+	var blk *oasis.Block
+	for i, rawTx := range txsWithRes.Transactions {
+		txHash := hash.NewFromBytes(rawTx)
+		if _, ok := unresolved[txHash]; !ok {
+			continue
+		}
+		if blk == nil {
+			if blk, err = t.oasisClient.GetBlock(ctx, height); err != nil {
+				return fmt.Errorf("unable to get block: %w", err)
+			}
+		}
+		if err = t.setIncluded(txHash, blk, txsWithRes.Results[i]); err != nil {
+			return fmt.Errorf("unable to update tracked transaction: %w", err)
+		}
+		delete(unresolved, txHash)
+	}
+	return nil
+}
+
+// trackHeight returns the height to pass to track for transactions submitted
+// afterwards if the tracker is enabled.  Failures are only logged, in which
+// case the tracker doesn't check earlier blocks for the transactions.
+func (s *constructionAPIService) trackHeight(ctx context.Context) int64 {
+	if s.tracker == nil {
+		return 0
+	}
+	height, err := s.tracker.Height(ctx)
+	if err != nil {
+		loggerTrk.Error("failed to get height for tracking", "err", err)
+	}
+	return height
+}
+
+// track starts tracking the given submitted transaction if the tracker is
+// enabled.  The height must have been obtained from trackHeight before the
+// submission.  Failures are only logged, since the transaction was submitted.
+func (s *constructionAPIService) track(tx *transaction.SignedTransaction, height int64) {
+	if s.tracker == nil {
+		return
+	}
+	if err := s.tracker.Track(tx, height); err != nil {
+		loggerTrk.Error("failed to track transaction",
+			"tx_hash", tx.Hash().String(),
+			"err", err,
+		)
+	}
+}
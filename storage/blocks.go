@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+)
+
+// MemoryBlockIndexSize is the maximum number of blocks kept in the block
+// hash -> height index if the store isn't persistent.  Beyond that, the least
+// recently used blocks are forgotten, so that the index doesn't grow without
+// bounds while the gateway serves a chain.
+const MemoryBlockIndexSize = 10000
+
+// blockHashKeyPrefix is the key prefix of the block hash -> height index.
+var blockHashKeyPrefix = []byte{0x01}
+
+func blockHashKey(blkHash hash.Hash) []byte {
+	return append(append([]byte{}, blockHashKeyPrefix...), blkHash[:]...)
+}
+
+// PutBlockHeight records the height of the block with the given hash.
+// If the store isn't persistent, only the most recently used
+// MemoryBlockIndexSize blocks are kept.
+func (s *Store) PutBlockHeight(blkHash hash.Hash, height int64) error {
+	if s.blockCache != nil {
+		return s.blockCache.Put(blkHash, height)
+	}
+
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], uint64(height))
+	return s.set(blockHashKey(blkHash), value[:])
+}
+
+// GetBlockHeight returns the height of the block with the given hash.
+// The second return value is false if the hash is not in the index.
+func (s *Store) GetBlockHeight(blkHash hash.Hash) (int64, bool, error) {
+	if s.blockCache != nil {
+		if height, ok := s.blockCache.Get(blkHash); ok {
+			return height.(int64), true, nil
+		}
+	}
+
+	// Indexed blocks are always recorded in the database.
+	value, err := s.get(blockHashKey(blkHash))
+	if err != nil {
+		return 0, false, err
+	}
+	if value == nil {
+		return 0, false, nil
+	}
+	if len(value) != 8 {
+		return 0, false, fmt.Errorf("malformed block height for block %s", blkHash)
+	}
+	return int64(binary.BigEndian.Uint64(value)), true, nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+)
+
+// blockEventKeyPrefix is the key prefix of the block event log, keyed by
+// sequence number.
+var blockEventKeyPrefix = []byte{0x07}
+
+// BlockEvent is an entry in the block event log.
+type BlockEvent struct {
+	// Sequence is the sequence number of the event.
+	Sequence int64 `json:"sequence"`
+	// Height is the height of the added block.
+	Height int64 `json:"height"`
+	// Hash is the hash of the added block.
+	Hash string `json:"hash"`
+}
+
+func blockEventKey(seq int64) []byte {
+	var rawSeq [8]byte
+	binary.BigEndian.PutUint64(rawSeq[:], uint64(seq))
+	return makeKey(blockEventKeyPrefix, rawSeq[:])
+}
+
+// getMaxBlockEventSequence returns the sequence number of the last event in
+// the block event log or -1 if the log is empty.
+func getMaxBlockEventSequence(txn *badger.Txn) int64 {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	opts.Prefix = blockEventKeyPrefix
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(blockEventKey(-1))
+	if !it.ValidForPrefix(blockEventKeyPrefix) {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint64(it.Item().Key()[len(blockEventKeyPrefix):]))
+}
+
+// appendBlockEvent appends an event for the given block to the block event
+// log.
+func appendBlockEvent(txn *badger.Txn, height int64, blkHash hash.Hash) error {
+	ev := BlockEvent{
+		Sequence: getMaxBlockEventSequence(txn) + 1,
+		Height:   height,
+		Hash:     blkHash.Hex(),
+	}
+	value, err := json.Marshal(&ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block event: %w", err)
+	}
+	return txn.Set(blockEventKey(ev.Sequence), value)
+}
+
+// GetMaxBlockEventSequence returns the sequence number of the last event in
+// the block event log or -1 if the log is empty.
+func (s *Store) GetMaxBlockEventSequence() (int64, error) {
+	seq := int64(-1)
+	err := s.db.View(func(txn *badger.Txn) error {
+		seq = getMaxBlockEventSequence(txn)
+		return nil
+	})
+	return seq, err
+}
+
+// GetBlockEvents returns at most limit events from the block event log,
+// starting with the given sequence number.
+func (s *Store) GetBlockEvents(offset, limit int64) ([]*BlockEvent, error) {
+	var evs []*BlockEvent
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = blockEventKeyPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(blockEventKey(offset)); it.ValidForPrefix(blockEventKeyPrefix); it.Next() {
+			if int64(len(evs)) >= limit {
+				break
+			}
+			var ev BlockEvent
+			if err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, &ev)
+			}); err != nil {
+				return fmt.Errorf("malformed block event: %w", err)
+			}
+			evs = append(evs, &ev)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return evs, nil
+}
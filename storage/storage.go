@@ -0,0 +1,102 @@
+// Package storage implements the gateway's local persistent storage.
+package storage
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	cmnBadger "github.com/oasisprotocol/oasis-core/go/common/badger"
+	"github.com/oasisprotocol/oasis-core/go/common/cache/lru"
+	"github.com/oasisprotocol/oasis-core/go/common/logging"
+)
+
+// DataDirEnvVar is the name of the environment variable that specifies the
+// directory in which the gateway keeps its local indices.  If it is not set,
+// the indices are kept in memory and are lost when the gateway restarts.
+const DataDirEnvVar = "OASIS_ROSETTA_GATEWAY_DATA_DIR"
+
+var logger = logging.GetLogger("storage")
+
+// Store is the gateway's local key-value store.
+type Store struct {
+	db *badger.DB
+	gc *cmnBadger.GCWorker
+
+	// blockCache is the bounded block hash -> height index used instead of
+	// the database if the store isn't persistent.
+	blockCache *lru.Cache
+}
+
+// Persistent returns true if the store is kept on disk, i.e. if it survives
+// restarts of the gateway.
+func (s *Store) Persistent() bool {
+	return s.gc != nil
+}
+
+// Close closes the store.
+func (s *Store) Close() {
+	if s.gc != nil {
+		s.gc.Close()
+	}
+	if err := s.db.Close(); err != nil {
+		logger.Error("failed to close database", "err", err)
+	}
+}
+
+// get returns the value stored under the given key or nil if there is none.
+func (s *Store) get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		switch err {
+		case nil:
+		case badger.ErrKeyNotFound:
+			return nil
+		default:
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// set stores the given value under the given key.
+func (s *Store) set(key, value []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+// Open opens the store in the given directory.  If dataDir is empty, an
+// in-memory store is created instead.
+func Open(dataDir string) (*Store, error) {
+	opts := badger.DefaultOptions(dataDir)
+	if dataDir == "" {
+		opts = opts.WithInMemory(true)
+	}
+	opts = opts.WithLogger(cmnBadger.NewLogAdapter(logger))
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database in '%s': %w", dataDir, err)
+	}
+
+	s := &Store{
+		db: db,
+	}
+	switch dataDir {
+	case "":
+		s.blockCache, err = lru.New(lru.Capacity(MemoryBlockIndexSize, false))
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create block index cache: %w", err)
+		}
+	default:
+		s.gc = cmnBadger.NewGCWorker(logger, db)
+	}
+	return s, nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction/results"
+)
+
+var (
+	// trackedTxKeyPrefix is the key prefix of transactions tracked after
+	// their submission, keyed by their hash.
+	trackedTxKeyPrefix = []byte{0x08}
+	// trackedHeightKey is the key of the height of the last block checked for
+	// tracked transactions.
+	trackedHeightKey = []byte{0x09}
+)
+
+// TrackedTransaction is a transaction submitted through the gateway whose
+// lifecycle is tracked.
+type TrackedTransaction struct {
+	// Hash is the hash of the transaction.
+	Hash string `json:"hash"`
+	// SignedTransaction is the CBOR-encoded signed transaction.
+	SignedTransaction []byte `json:"signed_transaction"`
+	// State is the lifecycle state of the transaction.
+	State string `json:"state"`
+	// SubmittedAt is the UNIX time in milliseconds of the last submission.
+	SubmittedAt int64 `json:"submitted_at"`
+	// SubmittedHeight is a height known before the last submission, so the
+	// blocks up to it can't include the transaction.
+	SubmittedHeight int64 `json:"submitted_height,omitempty"`
+	// Rebroadcasts is the number of times the transaction was resubmitted
+	// since it was last submitted by a client.
+	Rebroadcasts int `json:"rebroadcasts,omitempty"`
+	// Height is the height of the block including the transaction, if any.
+	Height int64 `json:"height,omitempty"`
+	// BlockHash is the hash of the block including the transaction, if any.
+	BlockHash string `json:"block_hash,omitempty"`
+	// Error is the error of the transaction if it failed.
+	Error *results.Error `json:"error,omitempty"`
+	// ResolvedAt is the UNIX time in milliseconds at which the transaction
+	// reached a final state, if it did.
+	ResolvedAt int64 `json:"resolved_at,omitempty"`
+}
+
+func trackedTxKey(txHash hash.Hash) []byte {
+	return makeKey(trackedTxKeyPrefix, txHash[:])
+}
+
+// PutTrackedTransaction stores the given tracked transaction.
+func (s *Store) PutTrackedTransaction(tx *TrackedTransaction) error {
+	var txHash hash.Hash
+	if err := txHash.UnmarshalHex(tx.Hash); err != nil {
+		return fmt.Errorf("malformed transaction hash: %w", err)
+	}
+	value, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tracked transaction: %w", err)
+	}
+	return s.set(trackedTxKey(txHash), value)
+}
+
+// GetTrackedTransaction returns the tracked transaction with the given hash or
+// nil if there is no such transaction.
+func (s *Store) GetTrackedTransaction(txHash hash.Hash) (*TrackedTransaction, error) {
+	value, err := s.get(trackedTxKey(txHash))
+	if err != nil || value == nil {
+		return nil, err
+	}
+	var tx TrackedTransaction
+	if err = json.Unmarshal(value, &tx); err != nil {
+		return nil, fmt.Errorf("malformed tracked transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+// DeleteTrackedTransactions deletes the tracked transactions with the given
+// hashes.
+func (s *Store) DeleteTrackedTransactions(txHashes []hash.Hash) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, txHash := range txHashes {
+			if err := txn.Delete(trackedTxKey(txHash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ForEachTrackedTransaction calls fn for all tracked transactions in the
+// order of their hashes.  The iteration stops when fn returns false.
+func (s *Store) ForEachTrackedTransaction(fn func(*TrackedTransaction) bool) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = trackedTxKeyPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.ValidForPrefix(trackedTxKeyPrefix); it.Next() {
+			var tx TrackedTransaction
+			if err := it.Item().Value(func(value []byte) error {
+				return json.Unmarshal(value, &tx)
+			}); err != nil {
+				return fmt.Errorf("malformed tracked transaction: %w", err)
+			}
+			if !fn(&tx) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// GetTrackedHeight returns the height of the last block checked for tracked
+// transactions.  The second return value is false if no block has been
+// checked yet.
+func (s *Store) GetTrackedHeight() (int64, bool, error) {
+	value, err := s.get(trackedHeightKey)
+	if err != nil {
+		return 0, false, err
+	}
+	if value == nil {
+		return 0, false, nil
+	}
+	if len(value) != 8 {
+		return 0, false, fmt.Errorf("malformed tracked height")
+	}
+	return int64(binary.BigEndian.Uint64(value)), true, nil
+}
+
+// PutTrackedHeight records the height of the last block checked for tracked
+// transactions.
+func (s *Store) PutTrackedHeight(height int64) error {
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], uint64(height))
+	return s.set(trackedHeightKey, value[:])
+}
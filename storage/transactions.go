@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+)
+
+var (
+	// txKeyPrefix is the key prefix of indexed transactions, keyed by their
+	// position (height, index within block).
+	txKeyPrefix = []byte{0x02}
+	// txHashKeyPrefix is the key prefix of the transaction hash -> position
+	// index.
+	txHashKeyPrefix = []byte{0x03}
+	// txAccountKeyPrefix is the key prefix of the account -> positions index.
+	txAccountKeyPrefix = []byte{0x04}
+	// txMethodKeyPrefix is the key prefix of the method -> positions index.
+	txMethodKeyPrefix = []byte{0x05}
+	// indexedHeightKey is the key of the last indexed height.
+	indexedHeightKey = []byte{0x06}
+)
+
+// positionSize is the size of an encoded transaction position.
+const positionSize = 8 + 4
+
+// IndexedTransaction is a transaction stored in the transaction index.
+type IndexedTransaction struct {
+	// Height is the height of the block containing the transaction.
+	Height int64 `json:"height"`
+	// Index is the position of the transaction within the block.
+	Index uint32 `json:"index"`
+	// BlockHash is the hash of the block containing the transaction.
+	BlockHash string `json:"block_hash"`
+	// Method is the transaction method or empty for block-level events.
+	Method string `json:"method,omitempty"`
+	// Signer is the transaction signer's address or empty for block-level
+	// events.
+	Signer string `json:"signer,omitempty"`
+	// Accounts are the addresses of all accounts affected by the transaction.
+	Accounts []staking.Address `json:"accounts"`
+	// Transaction is the decoded Rosetta transaction.
+	Transaction *types.Transaction `json:"transaction"`
+}
+
+func encodePosition(height int64, index uint32) []byte {
+	var pos [positionSize]byte
+	binary.BigEndian.PutUint64(pos[:8], uint64(height))
+	binary.BigEndian.PutUint32(pos[8:], index)
+	return pos[:]
+}
+
+func decodePositionHeight(pos []byte) int64 {
+	return int64(binary.BigEndian.Uint64(pos[:8]))
+}
+
+func makeKey(parts ...[]byte) []byte {
+	var key []byte
+	for _, part := range parts {
+		key = append(key, part...)
+	}
+	return key
+}
+
+func txAccountPrefix(addr staking.Address) []byte {
+	rawAddr, _ := addr.MarshalBinary()
+	return makeKey(txAccountKeyPrefix, rawAddr)
+}
+
+func txMethodPrefix(method string) []byte {
+	// Method names never contain a zero byte, so it can be used as a separator.
+	return makeKey(txMethodKeyPrefix, []byte(method), []byte{0x00})
+}
+
+// GetIndexedHeight returns the height of the last indexed block.
+// The second return value is false if nothing has been indexed yet.
+func (s *Store) GetIndexedHeight() (int64, bool, error) {
+	value, err := s.get(indexedHeightKey)
+	if err != nil {
+		return 0, false, err
+	}
+	if value == nil {
+		return 0, false, nil
+	}
+	if len(value) != 8 {
+		return 0, false, fmt.Errorf("malformed indexed height")
+	}
+	return int64(binary.BigEndian.Uint64(value)), true, nil
+}
+
+// PutIndexedBlock atomically adds the given transactions of the block at the
+// given height to the transaction index, records the block in the block hash
+// index and in the block event log, and marks the block as indexed.
+func (s *Store) PutIndexedBlock(height int64, blkHash hash.Hash, txs []*IndexedTransaction) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, tx := range txs {
+			if tx.Height != height {
+				return fmt.Errorf("transaction height mismatch (expected: %d got: %d)", height, tx.Height)
+			}
+			var txHash hash.Hash
+			if err := txHash.UnmarshalHex(tx.Transaction.TransactionIdentifier.Hash); err != nil {
+				return fmt.Errorf("malformed transaction hash: %w", err)
+			}
+			value, err := json.Marshal(tx)
+			if err != nil {
+				return fmt.Errorf("failed to marshal transaction: %w", err)
+			}
+
+			pos := encodePosition(tx.Height, tx.Index)
+			if err = txn.Set(makeKey(txKeyPrefix, pos), value); err != nil {
+				return err
+			}
+			if err = txn.Set(makeKey(txHashKeyPrefix, txHash[:]), pos); err != nil {
+				return err
+			}
+			for _, addr := range tx.Accounts {
+				if err = txn.Set(makeKey(txAccountPrefix(addr), pos), nil); err != nil {
+					return err
+				}
+			}
+			if tx.Method != "" {
+				if err = txn.Set(makeKey(txMethodPrefix(tx.Method), pos), nil); err != nil {
+					return err
+				}
+			}
+		}
+
+		var value [8]byte
+		binary.BigEndian.PutUint64(value[:], uint64(height))
+		if err := txn.Set(blockHashKey(blkHash), value[:]); err != nil {
+			return err
+		}
+		if err := appendBlockEvent(txn, height, blkHash); err != nil {
+			return err
+		}
+		return txn.Set(indexedHeightKey, value[:])
+	})
+}
+
+func getTransactionAt(txn *badger.Txn, pos []byte) (*IndexedTransaction, error) {
+	item, err := txn.Get(makeKey(txKeyPrefix, pos))
+	if err != nil {
+		return nil, err
+	}
+	var tx IndexedTransaction
+	if err = item.Value(func(value []byte) error {
+		return json.Unmarshal(value, &tx)
+	}); err != nil {
+		return nil, fmt.Errorf("malformed indexed transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+// GetTransaction returns the indexed transaction with the given hash or nil
+// if there is no such transaction.
+func (s *Store) GetTransaction(txHash hash.Hash) (*IndexedTransaction, error) {
+	var tx *IndexedTransaction
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(makeKey(txHashKeyPrefix, txHash[:]))
+		switch err {
+		case nil:
+		case badger.ErrKeyNotFound:
+			return nil
+		default:
+			return err
+		}
+		pos, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		tx, err = getTransactionAt(txn, pos)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// iterateTransactions calls fn for all transactions whose positions are
+// stored under the given prefix (as key suffixes), from the most recent to
+// the oldest, starting at maxHeight.  The iteration stops when fn returns
+// false.
+func (s *Store) iterateTransactions(
+	prefix []byte,
+	maxHeight int64,
+	fn func(*IndexedTransaction) bool,
+) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		seek := makeKey(prefix, encodePosition(maxHeight, ^uint32(0)))
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().Key()
+			if len(key) != len(prefix)+positionSize {
+				continue
+			}
+			pos := key[len(prefix):]
+			if decodePositionHeight(pos) > maxHeight {
+				continue
+			}
+			tx, err := getTransactionAt(txn, pos)
+			if err != nil {
+				return err
+			}
+			if !fn(tx) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// ForEachTransaction calls fn for all indexed transactions at or below
+// maxHeight, from the most recent to the oldest, until fn returns false.
+func (s *Store) ForEachTransaction(maxHeight int64, fn func(*IndexedTransaction) bool) error {
+	return s.iterateTransactions(txKeyPrefix, maxHeight, fn)
+}
+
+// ForEachAccountTransaction calls fn for all indexed transactions at or below
+// maxHeight that affect the given account, from the most recent to the oldest,
+// until fn returns false.
+func (s *Store) ForEachAccountTransaction(
+	addr staking.Address,
+	maxHeight int64,
+	fn func(*IndexedTransaction) bool,
+) error {
+	return s.iterateTransactions(txAccountPrefix(addr), maxHeight, fn)
+}
+
+// ForEachMethodTransaction calls fn for all indexed transactions at or below
+// maxHeight with the given method, from the most recent to the oldest, until
+// fn returns false.
+func (s *Store) ForEachMethodTransaction(
+	method string,
+	maxHeight int64,
+	fn func(*IndexedTransaction) bool,
+) error {
+	return s.iterateTransactions(txMethodPrefix(method), maxHeight, fn)
+}
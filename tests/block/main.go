@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func block(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	pbi *types.PartialBlockIdentifier,
+) (*types.BlockResponse, *types.Error) {
+	resp, re, err := rc.BlockAPI.Block(context.Background(), &types.BlockRequest{
+		NetworkIdentifier: ni,
+		BlockIdentifier:   pbi,
+	})
+	if err != nil && re == nil {
+		panic(fmt.Errorf("block: %w", err))
+	}
+	return resp, re
+}
+
+func blockTransaction(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	bi *types.BlockIdentifier,
+	ti *types.TransactionIdentifier,
+) *types.Transaction {
+	resp, re, err := rc.BlockAPI.BlockTransaction(context.Background(), &types.BlockTransactionRequest{
+		NetworkIdentifier:     ni,
+		BlockIdentifier:       bi,
+		TransactionIdentifier: ti,
+	})
+	if err != nil {
+		panic(fmt.Errorf("block transaction %s in block %d: %w", ti.Hash, bi.Index, err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("block transaction %s in block %d: %v", ti.Hash, bi.Index, re))
+	}
+	return resp.Transaction
+}
+
+func expectError(name string, re *types.Error, expected *types.Error) {
+	if re == nil || re.Code != expected.Code {
+		panic(fmt.Errorf("%s: got error %v, expected code %d", name, re, expected.Code))
+	}
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	status, re, err := rc.NetworkAPI.NetworkStatus(context.Background(), &types.NetworkRequest{
+		NetworkIdentifier: ni,
+	})
+	if err != nil {
+		panic(fmt.Errorf("network status: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("network status: %v", re))
+	}
+
+	var sawOtherTxs bool
+	for height := status.GenesisBlockIdentifier.Index; height <= status.CurrentBlockIdentifier.Index; height++ {
+		byIndex, re := block(rc, ni, &types.PartialBlockIdentifier{Index: &height})
+		if re != nil {
+			panic(fmt.Errorf("block %d: %v", height, re))
+		}
+		bi := byIndex.Block.BlockIdentifier
+
+		// Blocks can be looked up by hash alone.
+		byHash, re := block(rc, ni, &types.PartialBlockIdentifier{Hash: &bi.Hash})
+		if re != nil {
+			panic(fmt.Errorf("block %d by hash: %v", height, re))
+		}
+		if !reflect.DeepEqual(byHash, byIndex) {
+			fmt.Println("block by index", common.DumpJSON(byIndex))
+			fmt.Println("block by hash", common.DumpJSON(byHash))
+			panic(fmt.Errorf("block %d: lookup by hash mismatch", height))
+		}
+
+		// All transactions can be fetched one by one.
+		for _, tx := range byIndex.Block.Transactions {
+			if btx := blockTransaction(rc, ni, bi, tx.TransactionIdentifier); !reflect.DeepEqual(btx, tx) {
+				fmt.Println("transaction in block", common.DumpJSON(tx))
+				fmt.Println("block transaction", common.DumpJSON(btx))
+				panic(fmt.Errorf("block %d: transaction %s mismatch", height, tx.TransactionIdentifier.Hash))
+			}
+		}
+		for _, ti := range byIndex.OtherTransactions {
+			sawOtherTxs = true
+			btx := blockTransaction(rc, ni, bi, ti)
+			if btx.TransactionIdentifier.Hash != ti.Hash || len(btx.Operations) == 0 {
+				fmt.Println("block transaction", common.DumpJSON(btx))
+				panic(fmt.Errorf("block %d: other transaction %s mismatch", height, ti.Hash))
+			}
+		}
+	}
+	if !sawOtherTxs {
+		panic(fmt.Errorf("no block with more transactions than %s", services.BlockTxLimitEnvVar))
+	}
+
+	// The hash must match the index if both are given.
+	genesis := status.GenesisBlockIdentifier
+	current := status.CurrentBlockIdentifier
+	_, re = block(rc, ni, &types.PartialBlockIdentifier{Index: &genesis.Index, Hash: &current.Hash})
+	expectError("mismatching hash", re, services.ErrBlockHashMismatch)
+
+	// Unknown hashes aren't found, even when looked up again.
+	unknownHash := strings.Repeat("ab", 32)
+	for i := 0; i < 2; i++ {
+		_, re = block(rc, ni, &types.PartialBlockIdentifier{Hash: &unknownHash})
+		expectError("unknown hash", re, services.ErrBlockNotFound)
+	}
+}
@@ -1,9 +1,11 @@
 package common
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/coinbase/rosetta-sdk-go/client"
 	"github.com/coinbase/rosetta-sdk-go/keys"
@@ -19,6 +21,12 @@ import (
 
 const DstAddressText = "oasis1qpkant39yhx59sagnzpc8v0sg8aerwa3jyqde3ge"
 
+const gatewayURL = "http://localhost:8080"
+
+// blockSearchDepth is the number of blocks searched for block-level
+// transactions.
+const blockSearchDepth = 100
+
 var (
 	TestEntityAddressText, _ = TestEntity()
 
@@ -86,7 +94,7 @@ func getTestEntityAmount() *types.Amount {
 
 // NewRosettaClient returns a new Rosetta API Client for tests or panics.
 func NewRosettaClient() (*client.APIClient, *types.NetworkIdentifier) {
-	rClient := client.NewAPIClient(client.NewConfiguration("http://localhost:8080", "rosetta-sdk-go", nil))
+	rClient := client.NewAPIClient(client.NewConfiguration(gatewayURL, "rosetta-sdk-go", nil))
 	nl, rErr, err := rClient.NetworkAPI.NetworkList(context.Background(), &types.MetadataRequest{})
 	if err != nil {
 		panic(err)
@@ -100,3 +108,360 @@ func NewRosettaClient() (*client.APIClient, *types.NetworkIdentifier) {
 	fmt.Println("network identifiers", DumpJSON(nl.NetworkIdentifiers))
 	return rClient, nl.NetworkIdentifiers[0]
 }
+
+// CallExtension calls the gateway endpoint at the given path that isn't part
+// of the Rosetta API and decodes its response.  It panics if the request
+// fails, but returns the errors reported by the gateway.
+func CallExtension(path string, request interface{}, response interface{}) *types.Error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		panic(err)
+	}
+	resp, err := http.Post(gatewayURL+path, "application/json", bytes.NewReader(body)) //nolint:gosec,noctx
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", path, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var terr types.Error
+		if err = json.NewDecoder(resp.Body).Decode(&terr); err != nil {
+			panic(fmt.Errorf("%s: malformed error response: %w", path, err))
+		}
+		return &terr
+	}
+	if err = json.NewDecoder(resp.Body).Decode(response); err != nil {
+		panic(fmt.Errorf("%s: malformed response: %w", path, err))
+	}
+	return nil
+}
+
+// TransferOps returns the operations of a transfer of the given amount from
+// the test entity to the destination account.
+func TransferOps(amount string) []*types.Operation {
+	return []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: TestEntityAddressText,
+			},
+			Amount: &types.Amount{
+				Value:    "-" + amount,
+				Currency: services.OasisCurrency,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 1,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: DstAddressText,
+			},
+			Amount: &types.Amount{
+				Value:    amount,
+				Currency: services.OasisCurrency,
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: 0,
+				},
+			},
+		},
+	}
+}
+
+// Preprocess calls /construction/preprocess with the given operations and
+// metadata and returns the options for /construction/metadata.  It panics if
+// the request fails, but returns the errors reported by the gateway.
+func Preprocess(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	ops []*types.Operation,
+	md map[string]interface{},
+) (map[string]interface{}, *types.Error) {
+	resp, re, err := rc.ConstructionAPI.ConstructionPreprocess(context.Background(), &types.ConstructionPreprocessRequest{
+		NetworkIdentifier: ni,
+		Operations:        ops,
+		Metadata:          md,
+	})
+	if err != nil && re == nil {
+		panic(fmt.Errorf("preprocess: %w", err))
+	}
+	if re != nil {
+		return nil, re
+	}
+	fmt.Println("metadata options", DumpJSON(resp.Options))
+	return resp.Options, nil
+}
+
+// Metadata calls /construction/metadata with the given options and public
+// keys.  It panics if the request fails, but returns the errors reported by
+// the gateway.
+func Metadata(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	options map[string]interface{},
+	publicKeys []*types.PublicKey,
+) (*types.ConstructionMetadataResponse, *types.Error) {
+	resp, re, err := rc.ConstructionAPI.ConstructionMetadata(context.Background(), &types.ConstructionMetadataRequest{
+		NetworkIdentifier: ni,
+		Options:           options,
+		PublicKeys:        publicKeys,
+	})
+	if err != nil && re == nil {
+		panic(fmt.Errorf("metadata: %w", err))
+	}
+	if re != nil {
+		return nil, re
+	}
+	fmt.Println("metadata", DumpJSON(resp.Metadata))
+	return resp, nil
+}
+
+// Payloads calls /construction/payloads with the given operations and
+// metadata.  It panics if the request fails, but returns the errors reported
+// by the gateway.
+func Payloads(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	ops []*types.Operation,
+	md map[string]interface{},
+) (*types.ConstructionPayloadsResponse, *types.Error) {
+	resp, re, err := rc.ConstructionAPI.ConstructionPayloads(context.Background(), &types.ConstructionPayloadsRequest{
+		NetworkIdentifier: ni,
+		Operations:        ops,
+		Metadata:          md,
+	})
+	if err != nil && re == nil {
+		panic(fmt.Errorf("payloads: %w", err))
+	}
+	if re != nil {
+		return nil, re
+	}
+	fmt.Println("unsigned transaction", resp.UnsignedTransaction)
+	return resp, nil
+}
+
+// Sign signs the given payloads with the test entity's key or panics.
+func Sign(payloads []*types.SigningPayload) []*types.Signature {
+	_, kp := TestEntity()
+	rs := keys.SignerEdwards25519{KeyPair: kp}
+	sigs := make([]*types.Signature, 0, len(payloads))
+	for _, sp := range payloads {
+		sig, err := rs.Sign(sp, sp.SignatureType)
+		if err != nil {
+			panic(err)
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// Combine calls /construction/combine with the given unsigned transaction and
+// signatures and returns the signed transaction.  It panics if the request
+// fails, but returns the errors reported by the gateway.
+func Combine(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	unsignedTx string,
+	sigs []*types.Signature,
+) (string, *types.Error) {
+	resp, re, err := rc.ConstructionAPI.ConstructionCombine(context.Background(), &types.ConstructionCombineRequest{
+		NetworkIdentifier:   ni,
+		UnsignedTransaction: unsignedTx,
+		Signatures:          sigs,
+	})
+	if err != nil && re == nil {
+		panic(fmt.Errorf("combine: %w", err))
+	}
+	if re != nil {
+		return "", re
+	}
+	fmt.Println("signed transaction", resp.SignedTransaction)
+	return resp.SignedTransaction, nil
+}
+
+// Submit calls /construction/submit with the given signed transaction.  It
+// panics if the request fails, but returns the errors reported by the gateway.
+func Submit(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	signedTx string,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	resp, re, err := rc.ConstructionAPI.ConstructionSubmit(context.Background(), &types.ConstructionSubmitRequest{
+		NetworkIdentifier: ni,
+		SignedTransaction: signedTx,
+	})
+	if err != nil && re == nil {
+		panic(fmt.Errorf("submit: %w", err))
+	}
+	if re != nil {
+		return nil, re
+	}
+	fmt.Println("transaction hash", resp.TransactionIdentifier.Hash)
+	return resp, nil
+}
+
+// AccountBalance returns the balance of the given account at the latest
+// height or panics.
+func AccountBalance(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+) *types.AccountBalanceResponse {
+	return accountBalance(rc, ni, account, nil)
+}
+
+// AccountBalanceAt returns the balance of the given account at the given
+// height or panics.
+func AccountBalanceAt(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+	height int64,
+) *types.AccountBalanceResponse {
+	return accountBalance(rc, ni, account, &types.PartialBlockIdentifier{
+		Index: &height,
+	})
+}
+
+func accountBalance(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+	blk *types.PartialBlockIdentifier,
+) *types.AccountBalanceResponse {
+	resp, re, err := rc.AccountAPI.AccountBalance(context.Background(), &types.AccountBalanceRequest{
+		NetworkIdentifier: ni,
+		AccountIdentifier: account,
+		BlockIdentifier:   blk,
+	})
+	if err != nil {
+		panic(fmt.Errorf("account balance: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("account balance: %v", re))
+	}
+	fmt.Println("account balance", DumpJSON(account), DumpJSON(resp))
+	return resp
+}
+
+// TestEntityNonce returns the test entity's nonce at the latest height.
+func TestEntityNonce(rc *client.APIClient, ni *types.NetworkIdentifier) uint64 {
+	resp := AccountBalance(rc, ni, &types.AccountIdentifier{
+		Address: TestEntityAddressText,
+	})
+	// JSON numbers are decoded as float64.
+	return uint64(resp.Metadata[services.NonceKey].(float64))
+}
+
+// SignedTransaction constructs and signs a transaction with the given
+// operations, which must be signed by the test entity, with the next nonce
+// and the suggested fee.  It returns the signed transaction or panics.
+func SignedTransaction(rc *client.APIClient, ni *types.NetworkIdentifier, ops []*types.Operation) string {
+	_, kp := TestEntity()
+	options, re := Preprocess(rc, ni, ops, nil)
+	if re != nil {
+		panic(fmt.Errorf("preprocess: %v", re))
+	}
+	md, re := Metadata(rc, ni, options, []*types.PublicKey{kp.PublicKey})
+	if re != nil {
+		panic(fmt.Errorf("metadata: %v", re))
+	}
+	payloads, re := Payloads(rc, ni, ops, md.Metadata)
+	if re != nil {
+		panic(fmt.Errorf("payloads: %v", re))
+	}
+	signedTx, re := Combine(rc, ni, payloads.UnsignedTransaction, Sign(payloads.Payloads))
+	if re != nil {
+		panic(fmt.Errorf("combine: %v", re))
+	}
+	return signedTx
+}
+
+// SignedTransfer constructs and signs a transfer of the given amount from the
+// test entity to the destination account with the next nonce and the
+// suggested fee.  It returns the signed transaction.
+func SignedTransfer(rc *client.APIClient, ni *types.NetworkIdentifier, amount string) string {
+	return SignedTransaction(rc, ni, TransferOps(amount))
+}
+
+// SubmitAndWait submits the given signed transaction through the
+// /construction/submit_and_wait endpoint with the default timeout.  It panics
+// if the transaction isn't included in a block.
+func SubmitAndWait(ni *types.NetworkIdentifier, signedTx string) *services.ConstructionSubmitAndWaitResponse {
+	var resp services.ConstructionSubmitAndWaitResponse
+	re := CallExtension("/construction/submit_and_wait", &services.ConstructionSubmitAndWaitRequest{
+		NetworkIdentifier: ni,
+		SignedTransaction: signedTx,
+	}, &resp)
+	if re != nil {
+		panic(fmt.Errorf("submit and wait: %v", re))
+	}
+	fmt.Println("submit and wait", DumpJSON(&resp))
+	return &resp
+}
+
+// BlockTransaction returns the given transaction in the given block or
+// panics.
+func BlockTransaction(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	blk *types.BlockIdentifier,
+	tx *types.TransactionIdentifier,
+) *types.Transaction {
+	resp, re, err := rc.BlockAPI.BlockTransaction(context.Background(), &types.BlockTransactionRequest{
+		NetworkIdentifier:     ni,
+		BlockIdentifier:       blk,
+		TransactionIdentifier: tx,
+	})
+	if err != nil {
+		panic(fmt.Errorf("block transaction: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("block transaction: %v", re))
+	}
+	fmt.Println("block transaction", DumpJSON(resp.Transaction))
+	return resp.Transaction
+}
+
+// FindBlockLevelTransaction returns the most recent block-level transaction
+// (and its block) that matches the given function, searching the last
+// blockSearchDepth blocks.  It panics if there is no such transaction.
+func FindBlockLevelTransaction(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	match func(tx *types.Transaction) bool,
+) (*types.BlockIdentifier, *types.Transaction) {
+	var index *int64
+	for i := 0; i < blockSearchDepth; i++ {
+		resp, re, err := rc.BlockAPI.Block(context.Background(), &types.BlockRequest{
+			NetworkIdentifier: ni,
+			BlockIdentifier: &types.PartialBlockIdentifier{
+				Index: index,
+			},
+		})
+		if err != nil {
+			panic(fmt.Errorf("block: %w", err))
+		}
+		if re != nil {
+			panic(fmt.Errorf("block: %v", re))
+		}
+		blk := resp.Block
+		for _, tx := range blk.Transactions {
+			if tx.TransactionIdentifier.Hash == blk.BlockIdentifier.Hash && match(tx) {
+				fmt.Println("block-level transaction", DumpJSON(blk.BlockIdentifier), DumpJSON(tx))
+				return blk.BlockIdentifier, tx
+			}
+		}
+		if blk.ParentBlockIdentifier.Index == blk.BlockIdentifier.Index {
+			break
+		}
+		index = &blk.ParentBlockIdentifier.Index
+	}
+	panic(fmt.Errorf("no matching block-level transaction in the last %d blocks", blockSearchDepth))
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+// transferOps returns the operations of a transfer of the given amount in the
+// transaction with the given batch index.
+func transferOps(from string, amount string, batchIndex int, offset int64) []*types.Operation {
+	return []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: offset,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: from,
+			},
+			Amount: &types.Amount{
+				Value:    "-" + amount,
+				Currency: services.OasisCurrency,
+			},
+			Metadata: map[string]interface{}{
+				services.BatchIndexKey: batchIndex,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: offset + 1,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: common.DstAddressText,
+			},
+			Amount: &types.Amount{
+				Value:    amount,
+				Currency: services.OasisCurrency,
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: offset,
+				},
+			},
+			Metadata: map[string]interface{}{
+				services.BatchIndexKey: batchIndex,
+			},
+		},
+	}
+}
+
+func parse(rc *client.APIClient, ni *types.NetworkIdentifier, signed bool, tx string) *types.ConstructionParseResponse {
+	resp, re, err := rc.ConstructionAPI.ConstructionParse(context.Background(), &types.ConstructionParseRequest{
+		NetworkIdentifier: ni,
+		Signed:            signed,
+		Transaction:       tx,
+	})
+	if err != nil {
+		panic(fmt.Errorf("parse: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("parse: %v", re))
+	}
+	return resp
+}
+
+// checkParsed checks that the parsed operations contain the given transfers
+// in order and that all operations have the batch index of their transaction.
+func checkParsed(name string, resp *types.ConstructionParseResponse, amounts []string, nonce uint64) {
+	fmt.Println(name, "parsed operations", common.DumpJSON(resp.Operations))
+
+	var transfers []string
+	lastIndex := 0
+	for i, op := range resp.Operations {
+		if op.OperationIdentifier.Index != int64(i) {
+			panic(fmt.Errorf("%s: operation %d has index %d", name, i, op.OperationIdentifier.Index))
+		}
+		// JSON numbers are decoded as float64.
+		idxF64, ok := op.Metadata[services.BatchIndexKey].(float64)
+		if !ok {
+			panic(fmt.Errorf("%s: operation %d: missing batch index", name, i))
+		}
+		idx := int(idxF64)
+		if idx < lastIndex || idx >= len(amounts) {
+			panic(fmt.Errorf("%s: operation %d: unexpected batch index %d", name, i, idx))
+		}
+		lastIndex = idx
+		if op.Account.Address == common.DstAddressText {
+			if op.Amount.Value != amounts[len(transfers)] {
+				panic(fmt.Errorf("%s: operation %d: got amount %s, expected %s",
+					name, i, op.Amount.Value, amounts[len(transfers)]))
+			}
+			transfers = append(transfers, op.Amount.Value)
+		}
+	}
+	if len(transfers) != len(amounts) {
+		panic(fmt.Errorf("%s: got %d transfers, expected %d", name, len(transfers), len(amounts)))
+	}
+
+	// The nonce of a batch is the nonce of its first transaction.
+	if n := uint64(resp.Metadata[services.NonceKey].(float64)); n != nonce {
+		panic(fmt.Errorf("%s: got nonce %d, expected %d", name, n, nonce))
+	}
+}
+
+func main() { //nolint:funlen
+	testEntityAddress, testEntityKeyPair := common.TestEntity()
+	rc, ni := common.NewRosettaClient()
+
+	amounts := []string{"1000", "2000"}
+	var ops []*types.Operation
+	for i, amount := range amounts {
+		ops = append(ops, transferOps(testEntityAddress, amount, i, int64(len(ops)))...)
+	}
+	fmt.Println("operations", common.DumpJSON(ops))
+
+	options, re := common.Preprocess(rc, ni, ops, nil)
+	if re != nil {
+		panic(fmt.Errorf("preprocess: %v", re))
+	}
+	if txs, ok := options[services.OptionsTxKey].([]interface{}); !ok || len(txs) != len(amounts) {
+		panic(fmt.Errorf("preprocess: expected %d transactions to estimate the fee for", len(amounts)))
+	}
+
+	r3, re := common.Metadata(rc, ni, options, []*types.PublicKey{testEntityKeyPair.PublicKey})
+	if re != nil {
+		panic(fmt.Errorf("metadata: %v", re))
+	}
+	nonce := uint64(r3.Metadata[services.NonceKey].(float64))
+	gas := transaction.Gas(r3.Metadata[services.FeeGasKey].(float64))
+	var feeAmount quantity.Quantity
+	if err := feeAmount.UnmarshalText([]byte(r3.Metadata[services.FeeAmountKey].(string))); err != nil {
+		panic(err)
+	}
+
+	r4, re := common.Payloads(rc, ni, ops, r3.Metadata)
+	if re != nil {
+		panic(fmt.Errorf("payloads: %v", re))
+	}
+	fmt.Println("signing payloads", common.DumpJSON(r4.Payloads))
+	if len(r4.Payloads) != len(amounts) {
+		panic(fmt.Errorf("payloads: got %d payloads, expected %d", len(r4.Payloads), len(amounts)))
+	}
+
+	// The transactions use consecutive nonces and all pay the suggested fee.
+	uts, err := services.DecodeUnsignedTransactionBatch(r4.UnsignedTransaction)
+	if err != nil {
+		panic(err)
+	}
+	for i, ut := range uts {
+		var tx transaction.Transaction
+		if err = cbor.Unmarshal(ut.Tx, &tx); err != nil {
+			panic(err)
+		}
+		if tx.Nonce != nonce+uint64(i) {
+			panic(fmt.Errorf("transaction %d: got nonce %d, expected %d", i, tx.Nonce, nonce+uint64(i)))
+		}
+		if tx.Fee == nil || tx.Fee.Gas != gas || tx.Fee.Amount.Cmp(&feeAmount) != 0 {
+			panic(fmt.Errorf("transaction %d: unexpected fee %+v", i, tx.Fee))
+		}
+		if tx.Method != api.MethodTransfer {
+			panic(fmt.Errorf("transaction %d: unexpected method %s", i, tx.Method))
+		}
+		if ut.Signer != testEntityAddress {
+			panic(fmt.Errorf("transaction %d: unexpected signer %s", i, ut.Signer))
+		}
+	}
+
+	checkParsed("unsigned", parse(rc, ni, false, r4.UnsignedTransaction), amounts, nonce)
+
+	for i, sp := range r4.Payloads {
+		if sp.AccountIdentifier.Address != testEntityAddress {
+			panic(fmt.Errorf("payload %d: unexpected signer %s", i, sp.AccountIdentifier.Address))
+		}
+	}
+	signedTx, re := common.Combine(rc, ni, r4.UnsignedTransaction, common.Sign(r4.Payloads))
+	if re != nil {
+		panic(fmt.Errorf("combine: %v", re))
+	}
+
+	r5p := parse(rc, ni, true, signedTx)
+	checkParsed("signed", r5p, amounts, nonce)
+	if len(r5p.AccountIdentifierSigners) != 1 || r5p.AccountIdentifierSigners[0].Address != testEntityAddress {
+		panic(fmt.Errorf("signed: unexpected signers %s", common.DumpJSON(r5p.AccountIdentifierSigners)))
+	}
+
+	r6, re, err := rc.ConstructionAPI.ConstructionHash(context.Background(), &types.ConstructionHashRequest{
+		NetworkIdentifier: ni,
+		SignedTransaction: signedTx,
+	})
+	if err != nil {
+		panic(fmt.Errorf("hash: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("hash: %v", re))
+	}
+
+	r7, re := common.Submit(rc, ni, signedTx)
+	if re != nil {
+		panic(fmt.Errorf("submit: %v", re))
+	}
+	fmt.Println("transaction metadata", common.DumpJSON(r7.Metadata))
+
+	// A batch is identified by its first transaction and lists the hashes of
+	// all of them.
+	hashes, ok := r7.Metadata[services.TxHashesKey].([]interface{})
+	if !ok || len(hashes) != len(amounts) {
+		panic(fmt.Errorf("submit: expected %d transaction hashes", len(amounts)))
+	}
+	if hashes[0] != r7.TransactionIdentifier.Hash {
+		panic(fmt.Errorf("submit: batch not identified by its first transaction"))
+	}
+	if r6.TransactionIdentifier.Hash != r7.TransactionIdentifier.Hash {
+		panic(fmt.Errorf("hash: got %s, expected %s", r6.TransactionIdentifier.Hash, r7.TransactionIdentifier.Hash))
+	}
+}
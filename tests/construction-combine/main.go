@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+const dummyNonce = 3
+
+// payloads constructs a transfer with the given nonce.
+func payloads(rc *client.APIClient, ni *types.NetworkIdentifier, nonce uint64) *types.ConstructionPayloadsResponse {
+	resp, re := common.Payloads(rc, ni, common.TransferOps("1000"), map[string]interface{}{
+		services.NonceKey: nonce,
+	})
+	if re != nil {
+		panic(fmt.Errorf("payloads: %v", re))
+	}
+	return resp
+}
+
+func expectError(name string, re *types.Error, expected *types.Error) {
+	if re == nil || re.Code != expected.Code {
+		panic(fmt.Errorf("%s: got error %v, expected code %d", name, re, expected.Code))
+	}
+	fmt.Println(name, "error", common.DumpJSON(re))
+}
+
+func main() {
+	otherKeyPair, err := keys.GenerateKeypair(types.Edwards25519)
+	if err != nil {
+		panic(err)
+	}
+	otherRs := keys.SignerEdwards25519{KeyPair: otherKeyPair}
+
+	rc, ni := common.NewRosettaClient()
+
+	r1 := payloads(rc, ni, dummyNonce)
+	sp := r1.Payloads[0]
+
+	sig := common.Sign(r1.Payloads)[0]
+	if _, re := common.Combine(rc, ni, r1.UnsignedTransaction, []*types.Signature{sig}); re != nil {
+		panic(fmt.Errorf("valid signature: %v", re))
+	}
+
+	// Signatures by another key are rejected, even if they are valid.
+	otherSig, err := otherRs.Sign(sp, sp.SignatureType)
+	if err != nil {
+		panic(err)
+	}
+	_, re := common.Combine(rc, ni, r1.UnsignedTransaction, []*types.Signature{otherSig})
+	expectError("other signer", re, services.ErrSignerMismatch)
+
+	// Corrupted signatures are rejected.
+	badSig := *sig
+	badSig.Bytes = append([]byte{}, sig.Bytes...)
+	badSig.Bytes[0] ^= 0xff
+	_, re = common.Combine(rc, ni, r1.UnsignedTransaction, []*types.Signature{&badSig})
+	expectError("corrupted signature", re, services.ErrInvalidSignature)
+
+	// So are signatures of other payloads.
+	r2 := payloads(rc, ni, dummyNonce+1)
+	_, re = common.Combine(rc, ni, r2.UnsignedTransaction, []*types.Signature{sig})
+	expectError("signature of other payload", re, services.ErrInvalidSignature)
+
+	// Each transaction needs exactly one signature.
+	_, re = common.Combine(rc, ni, r1.UnsignedTransaction, []*types.Signature{sig, sig})
+	expectError("extra signature", re, services.ErrMalformedValue)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func metadata(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	policy map[string]interface{},
+	publicKeys []*types.PublicKey,
+) (*types.ConstructionMetadataResponse, *types.Error) {
+	options, re := common.Preprocess(rc, ni, common.TransferOps("1000"), policy)
+	if re != nil {
+		return nil, re
+	}
+	return common.Metadata(rc, ni, options, publicKeys)
+}
+
+// fee returns the suggested fee gas and amount from the given metadata.
+func fee(name string, resp *types.ConstructionMetadataResponse, re *types.Error) (uint64, string) {
+	if re != nil {
+		panic(fmt.Errorf("%s: %v", name, re))
+	}
+	fmt.Println(name, "suggested fee", common.DumpJSON(resp.SuggestedFee))
+
+	// JSON numbers are decoded as float64.
+	gasF64, ok := resp.Metadata[services.FeeGasKey].(float64)
+	if !ok || gasF64 <= 0 {
+		panic(fmt.Errorf("%s: missing gas estimate", name))
+	}
+	amount, ok := resp.Metadata[services.FeeAmountKey].(string)
+	if !ok {
+		panic(fmt.Errorf("%s: missing fee amount", name))
+	}
+	if len(resp.SuggestedFee) != 1 || resp.SuggestedFee[0].Value != amount ||
+		resp.SuggestedFee[0].Currency.Symbol != services.OasisCurrency.Symbol {
+		panic(fmt.Errorf("%s: suggested fee doesn't match fee amount %s", name, amount))
+	}
+	return uint64(gasF64), amount
+}
+
+// payloads constructs the transfer with the given metadata and returns the
+// unsigned transaction.
+func payloads(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	md map[string]interface{},
+) (*transaction.Transaction, *types.Error) {
+	resp, re := common.Payloads(rc, ni, common.TransferOps("1000"), md)
+	if re != nil {
+		return nil, re
+	}
+	ut, err := services.DecodeUnsignedTransaction(resp.UnsignedTransaction)
+	if err != nil {
+		panic(err)
+	}
+	var tx transaction.Transaction
+	if err = cbor.Unmarshal(ut.Tx, &tx); err != nil {
+		panic(err)
+	}
+	fmt.Println("unsigned transaction", common.DumpJSON(&tx))
+	return &tx, nil
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+	_, kp := common.TestEntity()
+
+	// Without the signer's public key, the fee can't be estimated.
+	resp, re := metadata(rc, ni, nil, nil)
+	if re != nil {
+		panic(fmt.Errorf("without public key: %v", re))
+	}
+	if _, ok := resp.Metadata[services.FeeGasKey]; ok || resp.SuggestedFee != nil {
+		panic(fmt.Errorf("without public key: unexpected fee estimate"))
+	}
+
+	resp, re = metadata(rc, ni, nil, []*types.PublicKey{kp.PublicKey})
+	gas, amount := fee("estimate", resp, re)
+
+	// The suggested fee is used for transactions without fee operations.
+	tx, re := payloads(rc, ni, resp.Metadata)
+	if re != nil {
+		panic(fmt.Errorf("estimate: payloads: %v", re))
+	}
+	if tx.Fee == nil || uint64(tx.Fee.Gas) != gas || tx.Fee.Amount.String() != amount {
+		panic(fmt.Errorf("estimate: transaction doesn't pay the suggested fee"))
+	}
+
+	// The gas estimate is scaled by the fee multiplier.
+	resp, re = metadata(rc, ni, map[string]interface{}{
+		services.FeeMultiplierKey: 2.,
+	}, []*types.PublicKey{kp.PublicKey})
+	if multGas, _ := fee("fee multiplier", resp, re); multGas != 2*gas {
+		panic(fmt.Errorf("fee multiplier: got gas %d, expected %d", multGas, 2*gas))
+	}
+
+	// A gas price above the node's minimum gas price is used instead of it.
+	minGasPrice, err := strconv.ParseUint(amount, 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	minGasPrice /= gas
+	gasPrice := minGasPrice + 1000
+	resp, re = metadata(rc, ni, map[string]interface{}{
+		services.GasPriceKey: strconv.FormatUint(gasPrice, 10),
+	}, []*types.PublicKey{kp.PublicKey})
+	priceGas, priceAmount := fee("gas price", resp, re)
+	if expected := strconv.FormatUint(priceGas*gasPrice, 10); priceAmount != expected {
+		panic(fmt.Errorf("gas price: got fee amount %s, expected %s", priceAmount, expected))
+	}
+
+	// A gas price below the minimum is ignored.
+	if minGasPrice > 0 {
+		resp, re = metadata(rc, ni, map[string]interface{}{
+			services.GasPriceKey: strconv.FormatUint(minGasPrice-1, 10),
+		}, []*types.PublicKey{kp.PublicKey})
+		if _, lowAmount := fee("low gas price", resp, re); lowAmount != amount {
+			panic(fmt.Errorf("low gas price: got fee amount %s, expected %s", lowAmount, amount))
+		}
+	}
+
+	// Transactions that pay more than the maximum fee aren't constructed.
+	maxFee := strconv.FormatUint(priceGas*gasPrice-1, 10)
+	resp, re = metadata(rc, ni, map[string]interface{}{
+		services.GasPriceKey: strconv.FormatUint(gasPrice, 10),
+		services.MaxFeeKey:   maxFee,
+	}, []*types.PublicKey{kp.PublicKey})
+	fee("max fee", resp, re)
+	if resp.Metadata[services.MaxFeeKey] != maxFee {
+		panic(fmt.Errorf("max fee: maximum fee not passed on to payloads"))
+	}
+	if _, re = payloads(rc, ni, resp.Metadata); re == nil || re.Code != services.ErrFeeExceedsMaximum.Code {
+		panic(fmt.Errorf("max fee: got error %v, expected code %d", re, services.ErrFeeExceedsMaximum.Code))
+	}
+	fmt.Println("max fee error details", common.DumpJSON(re.Details))
+
+	// A malformed fee policy is rejected.
+	if _, re = metadata(rc, ni, map[string]interface{}{
+		services.FeeMultiplierKey: -1.,
+	}, nil); re == nil {
+		panic(fmt.Errorf("malformed fee multiplier: expected an error"))
+	}
+}
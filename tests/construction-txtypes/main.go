@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
 
+	"github.com/coinbase/rosetta-sdk-go/client"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/oasisprotocol/oasis-core/go/common/cbor"
 	"github.com/oasisprotocol/oasis-core/go/common/quantity"
 	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	governance "github.com/oasisprotocol/oasis-core/go/governance/api"
 	"github.com/oasisprotocol/oasis-core/go/staking/api"
 
 	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
@@ -149,7 +152,7 @@ var (
 			Account: &types.AccountIdentifier{
 				Address: common.DstAddressText,
 				SubAccount: &types.SubAccountIdentifier{
-					Address: services.SubAccountEscrow,
+					Address: services.SubAccountEscrowActive,
 				},
 			},
 			Amount: &types.Amount{
@@ -192,7 +195,7 @@ var (
 			Account: &types.AccountIdentifier{
 				Address: common.DstAddressText,
 				SubAccount: &types.SubAccountIdentifier{
-					Address: services.SubAccountEscrow,
+					Address: services.SubAccountEscrowActive,
 				},
 			},
 			Metadata: map[string]interface{}{
@@ -214,8 +217,254 @@ var (
 			Shares:  *quantity.NewFromUint64(1000),
 		}),
 	}
+	opsAllow = []*types.Operation{
+		fee100Op1,
+		fee100Op2,
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 2,
+			},
+			Type: services.OpAllow,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 3,
+			},
+			Type: services.OpAllow,
+			Account: &types.AccountIdentifier{
+				Address: common.DstAddressText,
+			},
+			Metadata: map[string]interface{}{
+				services.AllowanceNegativeKey:     false,
+				services.AllowanceAmountChangeKey: "1000",
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: 2,
+				},
+			},
+		},
+	}
+	txAllow = &transaction.Transaction{
+		Nonce:  dummyNonce,
+		Fee:    fee100,
+		Method: api.MethodAllow,
+		Body: cbor.Marshal(api.Allow{
+			Beneficiary:  common.DstAddress,
+			AmountChange: *quantity.NewFromUint64(1000),
+		}),
+	}
+	opsWithdraw = []*types.Operation{
+		fee100Op1,
+		fee100Op2,
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 2,
+			},
+			Type: services.OpWithdraw,
+			Account: &types.AccountIdentifier{
+				Address: common.DstAddressText,
+			},
+			Amount: &types.Amount{
+				Value:    "-1000",
+				Currency: services.OasisCurrency,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 3,
+			},
+			Type: services.OpWithdraw,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+			Amount: &types.Amount{
+				Value:    "1000",
+				Currency: services.OasisCurrency,
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: 2,
+				},
+			},
+		},
+	}
+	txWithdraw = &transaction.Transaction{
+		Nonce:  dummyNonce,
+		Fee:    fee100,
+		Method: api.MethodWithdraw,
+		Body: cbor.Marshal(api.Withdraw{
+			From:   common.DstAddress,
+			Amount: *quantity.NewFromUint64(1000),
+		}),
+	}
+	opsCastVote = []*types.Operation{
+		fee100Op1,
+		fee100Op2,
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 2,
+			},
+			Type: services.OpCastVote,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+			Metadata: map[string]interface{}{
+				services.ProposalIDKey: "7",
+				services.VoteKey:       "yes",
+			},
+		},
+	}
+	txCastVote = &transaction.Transaction{
+		Nonce:  dummyNonce,
+		Fee:    fee100,
+		Method: governance.MethodCastVote,
+		Body: cbor.Marshal(governance.ProposalVote{
+			ID:   7,
+			Vote: governance.VoteYes,
+		}),
+	}
+	opsCastVoteLargeID = []*types.Operation{
+		fee100Op1,
+		fee100Op2,
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 2,
+			},
+			Type: services.OpCastVote,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+			Metadata: map[string]interface{}{
+				services.ProposalIDKey: "18446744073709551615",
+				services.VoteKey:       "abstain",
+			},
+		},
+	}
+	txCastVoteLargeID = &transaction.Transaction{
+		Nonce:  dummyNonce,
+		Fee:    fee100,
+		Method: governance.MethodCastVote,
+		Body: cbor.Marshal(governance.ProposalVote{
+			ID:   math.MaxUint64,
+			Vote: governance.VoteAbstain,
+		}),
+	}
+	opsCall = []*types.Operation{
+		fee100Op1,
+		fee100Op2,
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 2,
+			},
+			Type: services.OpCall,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+			Metadata: map[string]interface{}{
+				services.CallMethodKey: string(api.MethodAmendCommissionSchedule),
+				services.CallBodyKey: map[string]interface{}{
+					"amendment": map[string]interface{}{
+						"rates": []interface{}{
+							map[string]interface{}{
+								"start": 10.,
+								"rate":  "1000",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	txCall = &transaction.Transaction{
+		Nonce:  dummyNonce,
+		Fee:    fee100,
+		Method: api.MethodAmendCommissionSchedule,
+		Body: cbor.Marshal(api.AmendCommissionSchedule{
+			Amendment: api.CommissionSchedule{
+				Rates: []api.CommissionRateStep{
+					{
+						Start: 10,
+						Rate:  *quantity.NewFromUint64(1000),
+					},
+				},
+			},
+		}),
+	}
 )
 
+// parseQuantity parses the given base units or shares, panicking if they are
+// malformed.
+func parseQuantity(name string, v interface{}) *quantity.Quantity {
+	text, ok := v.(string)
+	if !ok {
+		panic(fmt.Errorf("%s: malformed value %v", name, v))
+	}
+	var q quantity.Quantity
+	if err := q.UnmarshalText([]byte(text)); err != nil {
+		panic(fmt.Errorf("%s: malformed value %s: %w", name, text, err))
+	}
+	return &q
+}
+
+// checkDelegated checks that the test entity's delegated balance is what its
+// delegation to the destination account is worth.
+func checkDelegated(rc *client.APIClient, ni *types.NetworkIdentifier) {
+	delegated := common.AccountBalance(rc, ni, &types.AccountIdentifier{
+		Address: common.TestEntityAddressText,
+		SubAccount: &types.SubAccountIdentifier{
+			Address: services.SubAccountDelegated,
+		},
+	})
+	height := delegated.BlockIdentifier.Index
+	delegatedTo, ok := delegated.Metadata[services.DelegatedToKey].(map[string]interface{})
+	if !ok {
+		panic(fmt.Errorf("delegated: malformed delegations %v", delegated.Metadata[services.DelegatedToKey]))
+	}
+
+	// The delegated balance is the total value of the delegations.
+	total := quantity.NewQuantity()
+	for escrow, v := range delegatedTo {
+		delegation, ok := v.(map[string]interface{})
+		if !ok {
+			panic(fmt.Errorf("delegated: malformed delegation to %s", escrow))
+		}
+		if err := total.Add(parseQuantity("delegated", delegation["balance"])); err != nil {
+			panic(err)
+		}
+	}
+	if delegated.Balances[0].Value != total.String() {
+		panic(fmt.Errorf("delegated: got balance %s, expected %s", delegated.Balances[0].Value, total))
+	}
+
+	// The delegation is worth its share of the escrow account's active pool
+	// at the same height.
+	delegation, ok := delegatedTo[common.DstAddressText].(map[string]interface{})
+	if !ok {
+		panic(fmt.Errorf("delegated: no delegation to %s", common.DstAddressText))
+	}
+	active := common.AccountBalanceAt(rc, ni, &types.AccountIdentifier{
+		Address: common.DstAddressText,
+		SubAccount: &types.SubAccountIdentifier{
+			Address: services.SubAccountEscrowActive,
+		},
+	}, height)
+	pool := api.SharePool{
+		Balance:     *parseQuantity("escrow active balance", active.Balances[0].Value),
+		TotalShares: *parseQuantity("escrow active shares", active.Metadata[services.ActiveSharesKey]),
+	}
+	worth, err := pool.StakeForShares(parseQuantity("delegated shares", delegation["shares"]))
+	if err != nil {
+		panic(err)
+	}
+	if delegation["balance"] != worth.String() {
+		panic(fmt.Errorf("delegated: delegation worth %v, expected %s", delegation["balance"], worth))
+	}
+}
+
 func main() {
 	rc, ni := common.NewRosettaClient()
 
@@ -228,6 +477,11 @@ func main() {
 		{"burn", opsBurn, txBurn},
 		{"add escrow", opsAddEscrow, txAddEscrow},
 		{"reclaim escrow", opsReclaimEscrow, txReclaimEscrow},
+		{"allow", opsAllow, txAllow},
+		{"withdraw", opsWithdraw, txWithdraw},
+		{"cast vote", opsCastVote, txCastVote},
+		{"cast vote with large proposal ID", opsCastVoteLargeID, txCastVoteLargeID},
+		{"call", opsCall, txCall},
 	} {
 		r2, re, err := rc.ConstructionAPI.ConstructionPayloads(context.Background(), &types.ConstructionPayloadsRequest{
 			NetworkIdentifier: ni,
@@ -279,4 +533,11 @@ func main() {
 			panic(fmt.Errorf("%s: operations mismatch", tt.name))
 		}
 	}
+
+	// Escrowed stake is counted in the signer's delegated sub-account.
+	resp := common.SubmitAndWait(ni, common.SignedTransaction(rc, ni, opsAddEscrow))
+	if !resp.Success {
+		panic(fmt.Errorf("add escrow: transaction failed: %v", resp.Error))
+	}
+	checkDelegated(rc, ni)
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	var (
+		offset     int64
+		limit      int64 = 10
+		lastHeight int64 = -1
+		numEvents  int64
+	)
+	for {
+		resp, re, err := rc.EventsAPI.EventsBlocks(context.Background(), &types.EventsBlocksRequest{
+			NetworkIdentifier: ni,
+			Offset:            &offset,
+			Limit:             &limit,
+		})
+		if err != nil {
+			panic(fmt.Errorf("events blocks: %w", err))
+		}
+		if re != nil {
+			panic(fmt.Errorf("events blocks: %v", re))
+		}
+		if len(resp.Events) == 0 {
+			if numEvents == 0 || resp.MaxSequence != numEvents-1 {
+				panic(fmt.Errorf("got %d events, but the max sequence is %d", numEvents, resp.MaxSequence))
+			}
+			break
+		}
+
+		for _, ev := range resp.Events {
+			if ev.Sequence != numEvents {
+				panic(fmt.Errorf("got sequence %d, expected %d", ev.Sequence, numEvents))
+			}
+			if ev.Type != types.ADDED {
+				panic(fmt.Errorf("event %d: unexpected type %s", ev.Sequence, ev.Type))
+			}
+			if lastHeight >= 0 && ev.BlockIdentifier.Index != lastHeight+1 {
+				panic(fmt.Errorf("event %d: got block %d, expected %d",
+					ev.Sequence, ev.BlockIdentifier.Index, lastHeight+1))
+			}
+			if ev.Sequence > resp.MaxSequence {
+				panic(fmt.Errorf("event %d: above max sequence %d", ev.Sequence, resp.MaxSequence))
+			}
+
+			// The block of each event can be fetched by its identifier.
+			blk, re, err := rc.BlockAPI.Block(context.Background(), &types.BlockRequest{
+				NetworkIdentifier: ni,
+				BlockIdentifier: &types.PartialBlockIdentifier{
+					Index: &ev.BlockIdentifier.Index,
+					Hash:  &ev.BlockIdentifier.Hash,
+				},
+			})
+			if err != nil {
+				panic(fmt.Errorf("event %d: block: %w", ev.Sequence, err))
+			}
+			if re != nil {
+				panic(fmt.Errorf("event %d: block: %v", ev.Sequence, re))
+			}
+			if blk.Block.BlockIdentifier.Hash != ev.BlockIdentifier.Hash {
+				panic(fmt.Errorf("event %d: block hash mismatch", ev.Sequence))
+			}
+
+			lastHeight = ev.BlockIdentifier.Index
+			numEvents++
+		}
+		offset += int64(len(resp.Events))
+	}
+	fmt.Println("block events", numEvents)
+}
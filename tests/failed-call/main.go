@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	memorySigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/memory"
+	registry "github.com/oasisprotocol/oasis-core/go/registry/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	// Unfreezing a node that doesn't exist passes the node's checks, so the
+	// transaction is included in a block as a failed transaction.
+	signer, err := memorySigner.NewSigner(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	nodeID := signer.Public().String()
+	ops := []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: services.OpCall,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+			Metadata: map[string]interface{}{
+				services.CallMethodKey: string(registry.MethodUnfreezeNode),
+				services.CallBodyKey: map[string]interface{}{
+					"node_id": nodeID,
+				},
+			},
+		},
+	}
+	resp := common.SubmitAndWait(ni, common.SignedTransaction(rc, ni, ops))
+	if resp.Success {
+		panic(fmt.Errorf("unfreeze node: expected a failed transaction"))
+	}
+
+	// The call is reported as a failed Call operation with the decoded body.
+	tx := common.BlockTransaction(rc, ni, resp.BlockIdentifier, resp.TransactionIdentifier)
+	var calls []*types.Operation
+	for _, op := range tx.Operations {
+		if op.Type == services.OpCall {
+			calls = append(calls, op)
+		}
+	}
+	if len(calls) != 1 {
+		panic(fmt.Errorf("unfreeze node: got %d call operations, expected 1", len(calls)))
+	}
+	call := calls[0]
+	if call.Status == nil || *call.Status != services.OpStatusFailed {
+		panic(fmt.Errorf("unfreeze node: got status %v, expected %s", call.Status, services.OpStatusFailed))
+	}
+	if call.Account.Address != common.TestEntityAddressText || call.Account.SubAccount != nil {
+		panic(fmt.Errorf("unfreeze node: unexpected account %s", common.DumpJSON(call.Account)))
+	}
+	if call.Metadata[services.CallMethodKey] != string(registry.MethodUnfreezeNode) {
+		panic(fmt.Errorf("unfreeze node: got method %v, expected %s",
+			call.Metadata[services.CallMethodKey], registry.MethodUnfreezeNode))
+	}
+	body, ok := call.Metadata[services.CallBodyKey].(map[string]interface{})
+	if !ok || body["node_id"] != nodeID {
+		panic(fmt.Errorf("unfreeze node: got body %v, expected node ID %s", call.Metadata[services.CallBodyKey], nodeID))
+	}
+	if _, ok = call.Metadata[services.CallBodyCBORKey]; ok {
+		panic(fmt.Errorf("unfreeze node: body wasn't decoded"))
+	}
+}
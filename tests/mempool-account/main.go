@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+// attempts is the number of transfers submitted until one is seen in the
+// mempool before it is included in a block.
+const attempts = 10
+
+func mempoolAccount(
+	ni *types.NetworkIdentifier,
+	address string,
+	direction string,
+) (*services.MempoolAccountResponse, *types.Error) {
+	var resp services.MempoolAccountResponse
+	re := common.CallExtension("/mempool/account", &services.MempoolAccountRequest{
+		NetworkIdentifier: ni,
+		AccountIdentifier: &types.AccountIdentifier{
+			Address: address,
+		},
+		Direction: direction,
+	}, &resp)
+	return &resp, re
+}
+
+// contains returns whether the given account's transactions in the mempool in
+// the given direction include the transaction with the given hash.
+func contains(ni *types.NetworkIdentifier, address string, direction string, txHash string) bool {
+	resp, re := mempoolAccount(ni, address, direction)
+	if re != nil {
+		panic(fmt.Errorf("mempool account %s %s: %v", address, direction, re))
+	}
+	for _, tx := range resp.Transactions {
+		if tx.TransactionIdentifier.Hash != txHash {
+			continue
+		}
+		fmt.Println("mempool account", address, direction, common.DumpJSON(tx))
+		if len(tx.Operations) == 0 {
+			panic(fmt.Errorf("mempool account %s %s: transaction %s not decoded", address, direction, txHash))
+		}
+		return true
+	}
+	return false
+}
+
+func submit(rc *client.APIClient, ni *types.NetworkIdentifier) string {
+	resp, re := common.Submit(rc, ni, common.SignedTransfer(rc, ni, "1000"))
+	if re != nil {
+		panic(fmt.Errorf("submit: %v", re))
+	}
+	return resp.TransactionIdentifier.Hash
+}
+
+func expectError(name string, re *types.Error, expected *types.Error) {
+	if re == nil || re.Code != expected.Code {
+		panic(fmt.Errorf("%s: got error %v, expected code %d", name, re, expected.Code))
+	}
+	fmt.Println(name, "error", common.DumpJSON(re))
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+	src, dst := common.TestEntityAddressText, common.DstAddressText
+
+	// Transactions only stay in the mempool until the next block and the
+	// gateway refreshes its mempool snapshot periodically, so retry until
+	// one is seen there.
+	var seen bool
+	for i := 0; i < attempts && !seen; i++ {
+		nonce := common.TestEntityNonce(rc, ni)
+		txHash := submit(rc, ni)
+		for j := 0; j < 8 && !seen; j++ {
+			time.Sleep(250 * time.Millisecond)
+			seen = contains(ni, src, services.MempoolDirectionOutgoing, txHash) &&
+				contains(ni, dst, services.MempoolDirectionIncoming, txHash) &&
+				contains(ni, dst, "", txHash)
+		}
+
+		// A transfer is never incoming for its signer or outgoing for its
+		// destination.
+		if contains(ni, src, services.MempoolDirectionIncoming, txHash) {
+			panic(fmt.Errorf("transfer %s is incoming for its signer", txHash))
+		}
+		if contains(ni, dst, services.MempoolDirectionOutgoing, txHash) {
+			panic(fmt.Errorf("transfer %s is outgoing for its destination", txHash))
+		}
+
+		// The next transfer needs the next nonce.
+		for j := 0; common.TestEntityNonce(rc, ni) == nonce; j++ {
+			if j == 240 {
+				panic(fmt.Errorf("transfer %s not included", txHash))
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+	if !seen {
+		panic(fmt.Errorf("no transfer seen in the mempool in %d attempts", attempts))
+	}
+
+	_, re := mempoolAccount(ni, src, "sideways")
+	expectError("invalid direction", re, services.ErrMalformedValue)
+	_, re = mempoolAccount(ni, "not an address", "")
+	expectError("invalid address", re, services.ErrInvalidAccountAddress)
+}
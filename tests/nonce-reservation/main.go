@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func metadata(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	options map[string]interface{},
+	publicKeys []*types.PublicKey,
+) (*types.ConstructionMetadataResponse, *types.Error) {
+	options[services.OptionsIDKey] = common.TestEntityAddressText
+	resp, re, err := rc.ConstructionAPI.ConstructionMetadata(context.Background(), &types.ConstructionMetadataRequest{
+		NetworkIdentifier: ni,
+		Options:           options,
+		PublicKeys:        publicKeys,
+	})
+	if err != nil && re == nil {
+		panic(fmt.Errorf("metadata: %w", err))
+	}
+	return resp, re
+}
+
+func nonce(name string, resp *types.ConstructionMetadataResponse, re *types.Error) uint64 {
+	if re != nil {
+		panic(fmt.Errorf("%s: %v", name, re))
+	}
+	fmt.Println(name, "metadata", common.DumpJSON(resp.Metadata))
+	// JSON numbers are decoded as float64.
+	return uint64(resp.Metadata[services.NonceKey].(float64))
+}
+
+func expectNonce(name string, resp *types.ConstructionMetadataResponse, re *types.Error, expected uint64) {
+	if n := nonce(name, resp, re); n != expected {
+		panic(fmt.Errorf("%s: got nonce %d, expected %d", name, n, expected))
+	}
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+	_, kp := common.TestEntity()
+
+	txStr := base64.StdEncoding.EncodeToString(cbor.Marshal(&transaction.Transaction{
+		Method: api.MethodTransfer,
+		Body: cbor.Marshal(api.Transfer{
+			To:     common.DstAddress,
+			Amount: *quantity.NewFromUint64(1000),
+		}),
+	}))
+
+	resp, re := metadata(rc, ni, map[string]interface{}{}, nil)
+	first := nonce("first", resp, re)
+
+	// Concurrent constructions get consecutive nonces.
+	resp, re = metadata(rc, ni, map[string]interface{}{}, nil)
+	expectNonce("second", resp, re, first+1)
+
+	// A batch reserves a nonce for each transaction.
+	resp, re = metadata(rc, ni, map[string]interface{}{
+		services.OptionsTxKey: []interface{}{txStr, txStr},
+	}, nil)
+	expectNonce("batch", resp, re, first+2)
+
+	// Failed requests don't reserve any nonces.
+	_, re = metadata(rc, ni, map[string]interface{}{
+		services.MaxFeeKey: "not a number",
+	}, nil)
+	if re == nil {
+		panic("malformed fee policy: expected an error")
+	}
+	_, re = metadata(rc, ni, map[string]interface{}{
+		services.OptionsTxKey: []interface{}{txStr, "not a transaction"},
+	}, []*types.PublicKey{kp.PublicKey})
+	if re == nil {
+		panic("malformed transaction: expected an error")
+	}
+
+	resp, re = metadata(rc, ni, map[string]interface{}{}, nil)
+	expectNonce("after failures", resp, re, first+4)
+}
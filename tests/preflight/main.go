@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+// submit constructs, signs and submits a transfer of the given amount with
+// the given construction metadata, overriding the nonce and the fee amount
+// if given.
+func submit(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	amount string,
+	md map[string]interface{},
+	nonce uint64,
+	feeAmount string,
+) *types.Error {
+	payloadsMd := make(map[string]interface{})
+	for k, v := range md {
+		payloadsMd[k] = v
+	}
+	payloadsMd[services.NonceKey] = nonce
+	if feeAmount != "" {
+		payloadsMd[services.FeeAmountKey] = feeAmount
+	}
+
+	r1, re := common.Payloads(rc, ni, common.TransferOps(amount), payloadsMd)
+	if re != nil {
+		panic(fmt.Errorf("payloads: %v", re))
+	}
+	signedTx, re := common.Combine(rc, ni, r1.UnsignedTransaction, common.Sign(r1.Payloads))
+	if re != nil {
+		panic(fmt.Errorf("combine: %v", re))
+	}
+	_, re = common.Submit(rc, ni, signedTx)
+	return re
+}
+
+func expectError(name string, re *types.Error, expected *types.Error) {
+	if re == nil || re.Code != expected.Code {
+		panic(fmt.Errorf("%s: got error %v, expected code %d", name, re, expected.Code))
+	}
+	fmt.Println(name, "error", common.DumpJSON(re))
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+	_, kp := common.TestEntity()
+
+	r1 := common.AccountBalance(rc, ni, &types.AccountIdentifier{
+		Address: common.TestEntityAddressText,
+	})
+	balance, ok := new(big.Int).SetString(r1.Balances[0].Value, 10)
+	if !ok {
+		panic(fmt.Errorf("malformed balance %s", r1.Balances[0].Value))
+	}
+
+	options, re := common.Preprocess(rc, ni, common.TransferOps("1000"), nil)
+	if re != nil {
+		panic(fmt.Errorf("preprocess: %v", re))
+	}
+	r2, re := common.Metadata(rc, ni, options, []*types.PublicKey{kp.PublicKey})
+	if re != nil {
+		panic(fmt.Errorf("metadata: %v", re))
+	}
+	md := r2.Metadata
+	// JSON numbers are decoded as float64.
+	nonce := uint64(md[services.NonceKey].(float64))
+
+	// Nonces that the node would reject are caught before submitting.
+	re = submit(rc, ni, "1000", md, nonce+100, "")
+	expectError("nonce too high", re, services.ErrInvalidNonce)
+	if nonce > 0 {
+		re = submit(rc, ni, "1000", md, nonce-1, "")
+		expectError("nonce too low", re, services.ErrInvalidNonce)
+	}
+
+	// So are transfers that the signer can't afford.
+	re = submit(rc, ni, new(big.Int).Add(balance, big.NewInt(1)).String(), md, nonce, "")
+	expectError("insufficient balance", re, services.ErrInsufficientBalance)
+
+	// And fees below the minimum gas price, if there is one.
+	if md[services.FeeAmountKey] != "0" {
+		re = submit(rc, ni, "1000", md, nonce, "0")
+		expectError("fee too low", re, services.ErrFeeTooLow)
+	}
+
+	// Valid transactions are submitted.
+	if re = submit(rc, ni, "1000", md, nonce, ""); re != nil {
+		panic(fmt.Errorf("valid transaction: %v", re))
+	}
+
+	// The next transaction may follow the one in the mempool.
+	if re = submit(rc, ni, "1000", md, nonce+1, ""); re != nil {
+		panic(fmt.Errorf("next transaction: %v", re))
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	memorySigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/memory"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+// escrowAmount is the amount escrowed before reclaiming parts of it.
+const escrowAmount = "1000"
+
+func addEscrowOps(amount string) []*types.Operation {
+	return []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+			Amount: &types.Amount{
+				Value:    "-" + amount,
+				Currency: services.OasisCurrency,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 1,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: common.DstAddressText,
+				SubAccount: &types.SubAccountIdentifier{
+					Address: services.SubAccountEscrowActive,
+				},
+			},
+			Amount: &types.Amount{
+				Value:    amount,
+				Currency: services.OasisCurrency,
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: 0,
+				},
+			},
+		},
+	}
+}
+
+func reclaimEscrowOps(account string, md map[string]interface{}) []*types.Operation {
+	return []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: services.OpReclaimEscrow,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 1,
+			},
+			Type: services.OpReclaimEscrow,
+			Account: &types.AccountIdentifier{
+				Address: account,
+				SubAccount: &types.SubAccountIdentifier{
+					Address: services.SubAccountEscrowActive,
+				},
+			},
+			Metadata: md,
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: 0,
+				},
+			},
+		},
+	}
+}
+
+// metadata runs /construction/preprocess and /construction/metadata for the
+// given operations.
+func metadata(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	ops []*types.Operation,
+) (map[string]interface{}, *types.Error) {
+	_, kp := common.TestEntity()
+	options, re := common.Preprocess(rc, ni, ops, nil)
+	if re != nil {
+		return nil, re
+	}
+	resp, re := common.Metadata(rc, ni, options, []*types.PublicKey{kp.PublicKey})
+	if re != nil {
+		return nil, re
+	}
+	return resp.Metadata, nil
+}
+
+// addEscrow escrows the given amount with the destination account and waits
+// until the transaction is included in a block.
+func addEscrow(rc *client.APIClient, ni *types.NetworkIdentifier, amount string) {
+	nonce := common.TestEntityNonce(rc, ni)
+	if _, re := common.Submit(rc, ni, common.SignedTransaction(rc, ni, addEscrowOps(amount))); re != nil {
+		panic(fmt.Errorf("add escrow: %v", re))
+	}
+
+	// The nonce only changes once the transaction is included.
+	for i := 0; i < 60; i++ {
+		time.Sleep(time.Second)
+		if common.TestEntityNonce(rc, ni) > nonce {
+			return
+		}
+	}
+	panic(fmt.Errorf("add escrow: transaction not included"))
+}
+
+// reclaim constructs a reclaim escrow transaction for the given amount and
+// returns the reclaimed shares and their estimated worth.
+func reclaim(rc *client.APIClient, ni *types.NetworkIdentifier, amount string) (*quantity.Quantity, *quantity.Quantity) {
+	ops := reclaimEscrowOps(common.DstAddressText, map[string]interface{}{
+		services.ReclaimEscrowAmountKey: amount,
+	})
+	md, re := metadata(rc, ni, ops)
+	if re != nil {
+		panic(fmt.Errorf("reclaim %s: %v", amount, re))
+	}
+	var shares quantity.Quantity
+	if err := shares.UnmarshalText([]byte(md[services.ReclaimEscrowSharesKey].(string))); err != nil {
+		panic(fmt.Errorf("reclaim %s: malformed shares: %w", amount, err))
+	}
+	if shares.IsZero() {
+		panic(fmt.Errorf("reclaim %s: no shares reclaimed", amount))
+	}
+
+	// The transaction reclaims the shares from the metadata.
+	r1, re := common.Payloads(rc, ni, ops, md)
+	if re != nil {
+		panic(fmt.Errorf("reclaim %s: payloads: %v", amount, re))
+	}
+	ut, err := services.DecodeUnsignedTransaction(r1.UnsignedTransaction)
+	if err != nil {
+		panic(err)
+	}
+	var tx transaction.Transaction
+	if err = cbor.Unmarshal(ut.Tx, &tx); err != nil {
+		panic(err)
+	}
+	var body api.ReclaimEscrow
+	if err = cbor.Unmarshal(tx.Body, &body); err != nil {
+		panic(err)
+	}
+	if tx.Method != api.MethodReclaimEscrow || body.Account != common.DstAddress || body.Shares.Cmp(&shares) != 0 {
+		panic(fmt.Errorf("reclaim %s: unexpected transaction %s", amount, common.DumpJSON(&tx)))
+	}
+
+	// Parsing reports the shares and estimates what they are worth.
+	r2, re, err := rc.ConstructionAPI.ConstructionParse(context.Background(), &types.ConstructionParseRequest{
+		NetworkIdentifier: ni,
+		Signed:            false,
+		Transaction:       r1.UnsignedTransaction,
+	})
+	if err != nil {
+		panic(fmt.Errorf("parse: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("parse: %v", re))
+	}
+	fmt.Println("parsed operations", common.DumpJSON(r2.Operations))
+	fmt.Println("parsed metadata", common.DumpJSON(r2.Metadata))
+	estimates, ok := r2.Metadata[services.ReclaimEscrowEstimatesKey].([]interface{})
+	if !ok || len(estimates) != 1 {
+		panic(fmt.Errorf("reclaim %s: expected one estimate", amount))
+	}
+	estimate, ok := estimates[0].(map[string]interface{})
+	if !ok {
+		panic(fmt.Errorf("reclaim %s: malformed estimate", amount))
+	}
+	var worth quantity.Quantity
+	if err = worth.UnmarshalText([]byte(estimate["amount"].(string))); err != nil {
+		panic(fmt.Errorf("reclaim %s: malformed estimate: %w", amount, err))
+	}
+	return &shares, &worth
+}
+
+// checkOp checks the type, account and sub-account of the given operation.
+func checkOp(name string, op *types.Operation, opType string, account string, subAccount string) {
+	if op.Type != opType || op.Account.Address != account {
+		panic(fmt.Errorf("%s: unexpected operation %s", name, common.DumpJSON(op)))
+	}
+	switch {
+	case subAccount == "" && op.Account.SubAccount == nil:
+	case subAccount != "" && op.Account.SubAccount != nil && op.Account.SubAccount.Address == subAccount:
+	default:
+		panic(fmt.Errorf("%s: unexpected sub-account in %s", name, common.DumpJSON(op)))
+	}
+}
+
+// checkMove checks that the given pair of operations moves a positive amount
+// from the first operation's account to the second one's and returns the
+// amount.
+func checkMove(name string, from *types.Operation, to *types.Operation) *quantity.Quantity {
+	var amount quantity.Quantity
+	if err := amount.UnmarshalText([]byte(to.Amount.Value)); err != nil || amount.IsZero() {
+		panic(fmt.Errorf("%s: amount %s isn't positive", name, to.Amount.Value))
+	}
+	if from.Amount.Value != "-"+to.Amount.Value {
+		panic(fmt.Errorf("%s: amount %s doesn't match %s", name, from.Amount.Value, to.Amount.Value))
+	}
+	if len(to.RelatedOperations) != 1 || to.RelatedOperations[0].Index != from.OperationIdentifier.Index {
+		panic(fmt.Errorf("%s: unrelated operations", name))
+	}
+	return &amount
+}
+
+// balanceChange returns the change of the balance of the given sub-account of
+// the destination account in the block at the given height.
+func balanceChange(rc *client.APIClient, ni *types.NetworkIdentifier, subAccount string, height int64) *big.Int {
+	account := &types.AccountIdentifier{
+		Address: common.DstAddressText,
+		SubAccount: &types.SubAccountIdentifier{
+			Address: subAccount,
+		},
+	}
+	before := common.AccountBalanceAt(rc, ni, account, height-1)
+	after := common.AccountBalanceAt(rc, ni, account, height)
+	change, ok := new(big.Int).SetString(after.Balances[0].Value, 10)
+	if !ok {
+		panic(fmt.Errorf("malformed balance %s", after.Balances[0].Value))
+	}
+	previous, ok := new(big.Int).SetString(before.Balances[0].Value, 10)
+	if !ok {
+		panic(fmt.Errorf("malformed balance %s", before.Balances[0].Value))
+	}
+	return change.Sub(change, previous)
+}
+
+// startDebonding reclaims the given amount from the destination account and
+// checks the debonding start operations of the transaction.
+func startDebonding(rc *client.APIClient, ni *types.NetworkIdentifier, amount string) {
+	shares, worth := reclaim(rc, ni, amount)
+	ops := reclaimEscrowOps(common.DstAddressText, map[string]interface{}{
+		services.ReclaimEscrowAmountKey: amount,
+	})
+	resp := common.SubmitAndWait(ni, common.SignedTransaction(rc, ni, ops))
+	if !resp.Success {
+		panic(fmt.Errorf("start debonding: transaction failed: %v", resp.Error))
+	}
+	tx := common.BlockTransaction(rc, ni, resp.BlockIdentifier, resp.TransactionIdentifier)
+
+	// The reclaimed stake is moved from the escrow account's active pool to
+	// its debonding pool.
+	var debondingStart []*types.Operation
+	for _, op := range tx.Operations {
+		if op.Type == services.OpDebondingStart {
+			debondingStart = append(debondingStart, op)
+		}
+	}
+	if len(debondingStart) != 2 {
+		panic(fmt.Errorf("start debonding: got %d debonding start operations, expected 2", len(debondingStart)))
+	}
+	active, debonding := debondingStart[0], debondingStart[1]
+	checkOp("start debonding", active, services.OpDebondingStart, common.DstAddressText, services.SubAccountEscrowActive)
+	checkOp("start debonding", debonding, services.OpDebondingStart, common.DstAddressText, services.SubAccountEscrowDebonding)
+	if moved := checkMove("start debonding", active, debonding); moved.Cmp(worth) != 0 {
+		panic(fmt.Errorf("start debonding: moved %s, expected %s", moved, worth))
+	}
+
+	for _, op := range debondingStart {
+		if op.Metadata[services.DebondingOwnerKey] != common.TestEntityAddressText {
+			panic(fmt.Errorf("start debonding: unexpected owner %v", op.Metadata[services.DebondingOwnerKey]))
+		}
+	}
+	if active.Metadata[services.ActiveSharesChangeKey] != shares.String() {
+		panic(fmt.Errorf("start debonding: got active shares %v, expected %s",
+			active.Metadata[services.ActiveSharesChangeKey], shares))
+	}
+	if _, ok := debonding.Metadata[services.DebondingSharesChangeKey].(string); !ok {
+		panic(fmt.Errorf("start debonding: missing debonding shares"))
+	}
+	// JSON numbers are decoded as float64.
+	if _, ok := debonding.Metadata[services.DebondEndEpochKey].(float64); !ok {
+		panic(fmt.Errorf("start debonding: missing debond end epoch"))
+	}
+
+	// The balances of the pools change accordingly.
+	height := resp.BlockIdentifier.Index
+	if change := balanceChange(rc, ni, services.SubAccountEscrowActive, height); change.Cmp(new(big.Int).Neg(worth.ToBigInt())) != 0 {
+		panic(fmt.Errorf("start debonding: active balance changed by %s, expected -%s", change, worth))
+	}
+	if change := balanceChange(rc, ni, services.SubAccountEscrowDebonding, height); change.Cmp(worth.ToBigInt()) != 0 {
+		panic(fmt.Errorf("start debonding: debonding balance changed by %s, expected %s", change, worth))
+	}
+}
+
+// checkDebonded checks the operations that reclaim the destination account's
+// debonding stake once it has debonded.
+func checkDebonded(rc *client.APIClient, ni *types.NetworkIdentifier) {
+	isReclaim := func(op *types.Operation) bool {
+		return op.Type == services.OpTransfer && op.Account.Address == common.DstAddressText &&
+			op.Account.SubAccount != nil && op.Account.SubAccount.Address == services.SubAccountEscrowDebonding
+	}
+	blk, tx := common.FindBlockLevelTransaction(rc, ni, func(tx *types.Transaction) bool {
+		for _, op := range tx.Operations {
+			if isReclaim(op) {
+				return true
+			}
+		}
+		return false
+	})
+
+	// The stake is moved from the escrow account's debonding pool to the
+	// owner's general account.
+	reclaimed := new(big.Int)
+	for i, op := range tx.Operations {
+		if !isReclaim(op) {
+			continue
+		}
+		if i+1 == len(tx.Operations) {
+			panic(fmt.Errorf("reclaim at height %d: missing owner operation", blk.Index))
+		}
+		checkOp("reclaim", tx.Operations[i+1], services.OpTransfer, common.TestEntityAddressText, "")
+		reclaimed.Add(reclaimed, checkMove("reclaim", op, tx.Operations[i+1]).ToBigInt())
+	}
+
+	// The debonding pool's balance changes accordingly.
+	if change := balanceChange(rc, ni, services.SubAccountEscrowDebonding, blk.Index); change.Cmp(reclaimed.Neg(reclaimed)) != 0 {
+		panic(fmt.Errorf("reclaim at height %d: debonding balance changed by %s, expected %s", blk.Index, change, reclaimed))
+	}
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	// Once the epoch has advanced, the debonded stake is reclaimed.
+	if len(os.Args) > 1 && os.Args[1] == "debonded" {
+		checkDebonded(rc, ni)
+		return
+	}
+
+	addEscrow(rc, ni, escrowAmount)
+
+	// The shares are rounded up, so at least the given amount is reclaimed.
+	shares, worth := reclaim(rc, ni, "400")
+	fmt.Println("reclaiming 400 takes", shares, "shares worth", worth)
+	if worth.Cmp(quantity.NewFromUint64(400)) < 0 {
+		panic(fmt.Errorf("reclaim 400: shares only worth %s", worth))
+	}
+
+	// The shares are capped at the delegated shares.
+	allShares, allWorth := reclaim(rc, ni, "1000000000000000")
+	fmt.Println("reclaiming everything takes", allShares, "shares worth", allWorth)
+	if allShares.Cmp(shares) <= 0 || allWorth.Cmp(worth) <= 0 {
+		panic(fmt.Errorf("reclaim everything: got %s shares worth %s", allShares, allWorth))
+	}
+	if again, _ := reclaim(rc, ni, "2000000000000000"); again.Cmp(allShares) != 0 {
+		panic(fmt.Errorf("reclaim everything: got %s shares, then %s shares", allShares, again))
+	}
+
+	// There is nothing to reclaim from an account without a delegation.
+	signer, err := memorySigner.NewSigner(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	noDelegation := services.StringFromAddress(api.NewAddress(signer.Public()))
+	if _, re := metadata(rc, ni, reclaimEscrowOps(noDelegation, map[string]interface{}{
+		services.ReclaimEscrowAmountKey: "400",
+	})); re == nil {
+		panic(fmt.Errorf("reclaim without delegation: expected an error"))
+	}
+
+	// The amount and the shares can't both be given.
+	if _, re := metadata(rc, ni, reclaimEscrowOps(common.DstAddressText, map[string]interface{}{
+		services.ReclaimEscrowAmountKey: "400",
+		services.ReclaimEscrowSharesKey: "400",
+	})); re == nil {
+		panic(fmt.Errorf("reclaim with amount and shares: expected an error"))
+	}
+
+	// Reclaiming starts debonding the stake, which is reclaimed at the end of
+	// the debonding period.
+	startDebonding(rc, ni, "400")
+}
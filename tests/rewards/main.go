@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/entity"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	"github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+// escrowAmount is the amount escrowed with the validator's entity, on which
+// its staking rewards are paid.
+const escrowAmount = "1000000000000"
+
+func escrowOps(escrow string, amount string) []*types.Operation {
+	return []*types.Operation{
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 0,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: common.TestEntityAddressText,
+			},
+			Amount: &types.Amount{
+				Value:    "-" + amount,
+				Currency: services.OasisCurrency,
+			},
+		},
+		{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: 1,
+			},
+			Type: services.OpTransfer,
+			Account: &types.AccountIdentifier{
+				Address: escrow,
+				SubAccount: &types.SubAccountIdentifier{
+					Address: services.SubAccountEscrowActive,
+				},
+			},
+			Amount: &types.Amount{
+				Value:    amount,
+				Currency: services.OasisCurrency,
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: 0,
+				},
+			},
+		},
+	}
+}
+
+// validatorEntity returns the address of the entity with the given
+// descriptor file.
+func validatorEntity(path string) string {
+	ent, err := entity.LoadDescriptor(path)
+	if err != nil {
+		panic(fmt.Errorf("malformed entity descriptor: %w", err))
+	}
+	return services.StringFromAddress(api.NewAddress(ent.ID))
+}
+
+// checkReward checks the staking reward operations at the given index in the
+// given block-level transaction.
+func checkReward(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	blk *types.BlockIdentifier,
+	ops []*types.Operation,
+	i int,
+) {
+	pool, reward := ops[i-1], ops[i]
+	fmt.Println("reward operations", common.DumpJSON(pool), common.DumpJSON(reward))
+
+	// The reward is taken from the common pool and added to the escrow
+	// account's active pool.
+	if pool.Type != services.OpReward || pool.Account.SubAccount != nil ||
+		pool.Account.Address != services.StringFromAddress(api.CommonPoolAddress) {
+		panic(fmt.Errorf("reward at height %d: not taken from the common pool", blk.Index))
+	}
+	if len(reward.RelatedOperations) != 1 || reward.RelatedOperations[0].Index != pool.OperationIdentifier.Index {
+		panic(fmt.Errorf("reward at height %d: unrelated operations", blk.Index))
+	}
+	var amount quantity.Quantity
+	if err := amount.UnmarshalText([]byte(reward.Amount.Value)); err != nil || amount.IsZero() {
+		panic(fmt.Errorf("reward at height %d: amount %s isn't positive", blk.Index, reward.Amount.Value))
+	}
+	if pool.Amount.Value != "-"+reward.Amount.Value {
+		panic(fmt.Errorf("reward at height %d: common pool amount %s doesn't match reward %s",
+			blk.Index, pool.Amount.Value, reward.Amount.Value))
+	}
+
+	// The metadata describes the escrow account's active pool before the
+	// reward, as reported by /account/balance at the previous height.
+	if reward.Metadata[services.RewardTypeKey] != services.RewardTypeStaking {
+		panic(fmt.Errorf("reward at height %d: unexpected reward type %v",
+			blk.Index, reward.Metadata[services.RewardTypeKey]))
+	}
+	before := common.AccountBalanceAt(rc, ni, reward.Account, blk.Index-1)
+	if reward.Metadata[services.RewardEscrowActiveBalanceKey] != before.Balances[0].Value {
+		panic(fmt.Errorf("reward at height %d: got active balance %v, expected %s",
+			blk.Index, reward.Metadata[services.RewardEscrowActiveBalanceKey], before.Balances[0].Value))
+	}
+	if reward.Metadata[services.RewardEscrowTotalSharesKey] != before.Metadata[services.ActiveSharesKey] {
+		panic(fmt.Errorf("reward at height %d: got total shares %v, expected %v",
+			blk.Index, reward.Metadata[services.RewardEscrowTotalSharesKey], before.Metadata[services.ActiveSharesKey]))
+	}
+
+	// Nothing else changes the escrow account's active pool in the block.
+	var expected quantity.Quantity
+	if err := expected.UnmarshalText([]byte(before.Balances[0].Value)); err != nil {
+		panic(err)
+	}
+	if err := expected.Add(&amount); err != nil {
+		panic(err)
+	}
+	after := common.AccountBalanceAt(rc, ni, reward.Account, blk.Index)
+	if after.Balances[0].Value != expected.String() {
+		panic(fmt.Errorf("reward at height %d: got active balance %s after the reward, expected %s",
+			blk.Index, after.Balances[0].Value, expected.String()))
+	}
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+	if len(os.Args) != 3 {
+		panic("usage: rewards escrow|check <entity descriptor>")
+	}
+	validator := validatorEntity(os.Args[2])
+
+	switch os.Args[1] {
+	case "escrow":
+		// Staking rewards are paid at the end of each epoch in proportion to
+		// the stake escrowed with the entities whose nodes signed blocks.
+		resp := common.SubmitAndWait(ni, common.SignedTransaction(rc, ni, escrowOps(validator, escrowAmount)))
+		if !resp.Success {
+			panic(fmt.Errorf("escrow: transaction failed: %v", resp.Error))
+		}
+	case "check":
+		blk, tx := common.FindBlockLevelTransaction(rc, ni, func(tx *types.Transaction) bool {
+			for _, op := range tx.Operations {
+				if op.Type == services.OpReward && op.Account.Address == validator {
+					return true
+				}
+			}
+			return false
+		})
+		var numRewards int
+		for i, op := range tx.Operations {
+			if op.Type != services.OpReward || op.Account.Address != validator {
+				continue
+			}
+			if op.Account.SubAccount == nil || op.Account.SubAccount.Address != services.SubAccountEscrowActive || i == 0 {
+				panic(fmt.Errorf("reward at height %d: not added to the escrow account", blk.Index))
+			}
+			checkReward(rc, ni, blk, tx.Operations, i)
+			numRewards++
+		}
+		if numRewards != 1 {
+			panic(fmt.Errorf("reward at height %d: got %d rewards, expected 1", blk.Index, numRewards))
+		}
+	default:
+		panic(fmt.Errorf("unknown command %s", os.Args[1]))
+	}
+}
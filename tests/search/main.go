@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func search(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	request types.SearchTransactionsRequest,
+	offset int64,
+	limit int64,
+) *types.SearchTransactionsResponse {
+	request.NetworkIdentifier = ni
+	request.Offset = &offset
+	request.Limit = &limit
+	resp, re, err := rc.SearchAPI.SearchTransactions(context.Background(), &request)
+	if err != nil {
+		panic(fmt.Errorf("search: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("search: %v", re))
+	}
+	return resp
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	maxTotalCount, err := strconv.ParseInt(os.Getenv(services.SearchMaxTotalCountEnvVar), 10, 64)
+	if err != nil {
+		panic(fmt.Errorf("%s must be set: %w", services.SearchMaxTotalCountEnvVar, err))
+	}
+
+	for _, tt := range []struct {
+		name    string
+		request types.SearchTransactionsRequest
+	}{
+		{"all", types.SearchTransactionsRequest{}},
+		{"address", types.SearchTransactionsRequest{
+			Address: &common.TestEntityAddressText,
+		}},
+	} {
+		all := search(rc, ni, tt.request, 0, services.MaxSearchLimit)
+		fmt.Println(tt.name, "total count", all.TotalCount)
+		if all.TotalCount != int64(len(all.Transactions)) {
+			panic(fmt.Errorf("%s: total count %d doesn't match %d transactions",
+				tt.name, all.TotalCount, len(all.Transactions)))
+		}
+		if all.TotalCount <= maxTotalCount {
+			panic(fmt.Errorf("%s: only %d transactions, need more than %d to page past the cap",
+				tt.name, all.TotalCount, maxTotalCount))
+		}
+
+		// Page through the results one by one, well past the total count cap.
+		var paged []*types.BlockTransaction
+		offset := int64(0)
+		for {
+			page := search(rc, ni, tt.request, offset, 1)
+			paged = append(paged, page.Transactions...)
+			if page.TotalCount < offset+int64(len(page.Transactions)) {
+				panic(fmt.Errorf("%s: total count %d at offset %d is too low",
+					tt.name, page.TotalCount, offset))
+			}
+			if page.NextOffset == nil {
+				break
+			}
+			if *page.NextOffset != offset+1 {
+				panic(fmt.Errorf("%s: unexpected next offset %d at offset %d",
+					tt.name, *page.NextOffset, offset))
+			}
+			offset = *page.NextOffset
+		}
+
+		if len(paged) != len(all.Transactions) {
+			panic(fmt.Errorf("%s: paged through %d transactions, expected %d",
+				tt.name, len(paged), len(all.Transactions)))
+		}
+		for i, tx := range paged {
+			if tx.Transaction.TransactionIdentifier.Hash != all.Transactions[i].Transaction.TransactionIdentifier.Hash {
+				panic(fmt.Errorf("%s: transaction %d mismatch", tt.name, i))
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	"github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/oasis"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/storage"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+const (
+	chainID = "test"
+	height  = 42
+)
+
+var blkHash = hash.NewFromBytes([]byte("slash"))
+
+// fixtureClient is an Oasis client that serves a single block without
+// transactions whose staking events are given.  Calling any other method
+// panics.
+type fixtureClient struct {
+	oasis.Client
+
+	events []*api.Event
+}
+
+func (c *fixtureClient) GetChainID(ctx context.Context) (string, error) {
+	return chainID, nil
+}
+
+func (c *fixtureClient) GetBlock(ctx context.Context, h int64) (*oasis.Block, error) {
+	if h != height {
+		return nil, fmt.Errorf("no block at height %d", h)
+	}
+	return &oasis.Block{
+		Height:       height,
+		Hash:         blkHash.Hex(),
+		ParentHeight: height - 1,
+		ParentHash:   hash.NewFromBytes([]byte("parent")).Hex(),
+	}, nil
+}
+
+func (c *fixtureClient) GetTransactionsWithResults(
+	ctx context.Context,
+	h int64,
+) (*consensus.TransactionsWithResults, error) {
+	return &consensus.TransactionsWithResults{}, nil
+}
+
+func (c *fixtureClient) GetStakingEvents(ctx context.Context, h int64) ([]*api.Event, error) {
+	return c.events, nil
+}
+
+// takeEvent returns a block-level event that takes the given amounts from the
+// destination account's pools.
+func takeEvent(amount, debondingAmount uint64) *api.Event {
+	// Block-level events have the hash of an empty transaction.
+	var txHash hash.Hash
+	txHash.Empty()
+	return &api.Event{
+		Height: height,
+		TxHash: txHash,
+		Escrow: &api.EscrowEvent{
+			Take: &api.TakeEscrowEvent{
+				Owner:           common.DstAddress,
+				Amount:          *quantity.NewFromUint64(amount),
+				DebondingAmount: *quantity.NewFromUint64(debondingAmount),
+			},
+		},
+	}
+}
+
+// blockOps returns the operations of the block-level transaction of the block
+// with the given staking events.
+func blockOps(store *storage.Store, events []*api.Event) []*types.Operation {
+	bs := services.NewBlockAPIService(&fixtureClient{events: events}, store, 0)
+	index := int64(height)
+	resp, re := bs.Block(context.Background(), &types.BlockRequest{
+		NetworkIdentifier: &types.NetworkIdentifier{
+			Blockchain: services.OasisBlockchainName,
+			Network:    chainID,
+		},
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Index: &index,
+		},
+	})
+	if re != nil {
+		panic(fmt.Errorf("block: %v", re))
+	}
+	if len(resp.Block.Transactions) != 1 || resp.Block.Transactions[0].TransactionIdentifier.Hash != blkHash.Hex() {
+		panic(fmt.Errorf("block: unexpected transactions %s", common.DumpJSON(resp.Block.Transactions)))
+	}
+	ops := resp.Block.Transactions[0].Operations
+	fmt.Println("block operations", common.DumpJSON(ops))
+	return ops
+}
+
+// checkSlashOp checks the account, sub-account and amount of the given slash
+// operation.
+func checkSlashOp(name string, op *types.Operation, account string, subAccount string, amount string) {
+	if op.Type != services.OpSlash || op.Account.Address != account || op.Amount.Value != amount {
+		panic(fmt.Errorf("%s: unexpected operation %s", name, common.DumpJSON(op)))
+	}
+	switch {
+	case subAccount == "" && op.Account.SubAccount == nil:
+	case subAccount != "" && op.Account.SubAccount != nil && op.Account.SubAccount.Address == subAccount:
+	default:
+		panic(fmt.Errorf("%s: unexpected sub-account in %s", name, common.DumpJSON(op)))
+	}
+}
+
+// checkCommonPoolOp checks the operation that adds the slashed amount to the
+// common pool.
+func checkCommonPoolOp(name string, op *types.Operation, amount string, debondingAmount string) {
+	checkSlashOp(name, op, services.StringFromAddress(api.CommonPoolAddress), "", amount)
+	if op.Metadata[services.SlashedEscrowKey] != common.DstAddressText {
+		panic(fmt.Errorf("%s: got escrow %v, expected %s",
+			name, op.Metadata[services.SlashedEscrowKey], common.DstAddressText))
+	}
+	if op.Metadata[services.SlashedDebondingAmountKey] != debondingAmount {
+		panic(fmt.Errorf("%s: got debonding amount %v, expected %s",
+			name, op.Metadata[services.SlashedDebondingAmountKey], debondingAmount))
+	}
+}
+
+func main() {
+	store, err := storage.Open("")
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+
+	// The slashed amount is split between the active and the debonding pool.
+	ops := blockOps(store, []*api.Event{takeEvent(1000, 300)})
+	if len(ops) != 3 {
+		panic(fmt.Errorf("take from both pools: got %d operations, expected 3", len(ops)))
+	}
+	checkSlashOp("take from both pools", ops[0], common.DstAddressText, services.SubAccountEscrowActive, "-700")
+	checkSlashOp("take from both pools", ops[1], common.DstAddressText, services.SubAccountEscrowDebonding, "-300")
+	checkCommonPoolOp("take from both pools", ops[2], "1000", "300")
+
+	// Pools that aren't affected are omitted.
+	ops = blockOps(store, []*api.Event{takeEvent(500, 0)})
+	if len(ops) != 2 {
+		panic(fmt.Errorf("take from the active pool: got %d operations, expected 2", len(ops)))
+	}
+	checkSlashOp("take from the active pool", ops[0], common.DstAddressText, services.SubAccountEscrowActive, "-500")
+	checkCommonPoolOp("take from the active pool", ops[1], "500", "0")
+
+	ops = blockOps(store, []*api.Event{takeEvent(200, 200)})
+	if len(ops) != 2 {
+		panic(fmt.Errorf("take from the debonding pool: got %d operations, expected 2", len(ops)))
+	}
+	checkSlashOp("take from the debonding pool", ops[0], common.DstAddressText, services.SubAccountEscrowDebonding, "-200")
+	checkCommonPoolOp("take from the debonding pool", ops[1], "200", "200")
+
+	// Each operation is related to the previous one.
+	for i, op := range ops[1:] {
+		if len(op.RelatedOperations) != 1 || op.RelatedOperations[0].Index != ops[i].OperationIdentifier.Index {
+			panic(fmt.Errorf("take from the debonding pool: unrelated operations"))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func submitAndWait(
+	ni *types.NetworkIdentifier,
+	signedTx string,
+	timeout *int64,
+) (*services.ConstructionSubmitAndWaitResponse, *types.Error) {
+	var resp services.ConstructionSubmitAndWaitResponse
+	re := common.CallExtension("/construction/submit_and_wait", &services.ConstructionSubmitAndWaitRequest{
+		NetworkIdentifier: ni,
+		SignedTransaction: signedTx,
+		TimeoutSeconds:    timeout,
+	}, &resp)
+	if re != nil {
+		return nil, re
+	}
+	fmt.Println("submit and wait", common.DumpJSON(&resp))
+	return &resp, nil
+}
+
+// checkIncluded checks that the block in the given response includes the
+// transaction.
+func checkIncluded(rc *client.APIClient, ni *types.NetworkIdentifier, resp *services.ConstructionSubmitAndWaitResponse) {
+	common.BlockTransaction(rc, ni, resp.BlockIdentifier, resp.TransactionIdentifier)
+}
+
+func expectError(name string, re *types.Error, expected *types.Error) {
+	if re == nil || re.Code != expected.Code {
+		panic(fmt.Errorf("%s: got error %v, expected code %d", name, re, expected.Code))
+	}
+	fmt.Println(name, "error", common.DumpJSON(re))
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	timeout := int64(30)
+	signedTx := common.SignedTransfer(rc, ni, "1000")
+	resp, re := submitAndWait(ni, signedTx, &timeout)
+	if re != nil {
+		panic(fmt.Errorf("transfer: %v", re))
+	}
+	if !resp.Success || resp.Error != nil || resp.BlockIdentifier == nil {
+		panic(fmt.Errorf("transfer: expected successful inclusion"))
+	}
+	checkIncluded(rc, ni, resp)
+
+	// Transfers above the balance are only rejected when they are executed,
+	// so they are included in a block as failed transactions.
+	r1 := common.AccountBalance(rc, ni, &types.AccountIdentifier{
+		Address: common.TestEntityAddressText,
+	})
+	balance, ok := new(big.Int).SetString(r1.Balances[0].Value, 10)
+	if !ok {
+		panic(fmt.Errorf("malformed balance %s", r1.Balances[0].Value))
+	}
+	tooMuch := new(big.Int).Add(balance, big.NewInt(1)).String()
+	resp, re = submitAndWait(ni, common.SignedTransfer(rc, ni, tooMuch), &timeout)
+	if re != nil {
+		panic(fmt.Errorf("transfer above balance: %v", re))
+	}
+	if resp.Success || resp.BlockIdentifier == nil {
+		panic(fmt.Errorf("transfer above balance: expected failed inclusion"))
+	}
+	for _, key := range []string{services.ModuleKey, services.CodeKey, services.MsgKey} {
+		if _, ok := resp.Error[key]; !ok {
+			panic(fmt.Errorf("transfer above balance: error %s missing", key))
+		}
+	}
+	checkIncluded(rc, ni, resp)
+
+	// The default timeout is used if none is given.
+	if _, re = submitAndWait(ni, common.SignedTransfer(rc, ni, "1000"), nil); re != nil {
+		panic(fmt.Errorf("transfer without timeout: %v", re))
+	}
+
+	// Transactions that were already included can't be submitted again.
+	_, re = submitAndWait(ni, signedTx, &timeout)
+	expectError("resubmitted transaction", re, services.ErrUnableToSubmitTx)
+
+	invalidTimeout := int64(0)
+	_, re = submitAndWait(ni, common.SignedTransfer(rc, ni, "1000"), &invalidTimeout)
+	expectError("invalid timeout", re, services.ErrMalformedValue)
+	_, re = submitAndWait(ni, "not a transaction", nil)
+	expectError("malformed transaction", re, services.ErrMalformedValue)
+}
@@ -42,6 +42,7 @@ start_network() {
 		--fixture.default.setup_runtimes=false \
 		--fixture.default.num_entities=1 \
 		--fixture.default.epochtime_mock=true \
+		--fixture.default.staking_genesis "${ROOT}/test-rewards-staking-genesis.json" \
 		--basedir.no_temp_dir \
 		--basedir ${TEST_BASE_DIR} &
 
@@ -210,6 +211,19 @@ wait_for_nodes
 advance_epoch 6
 wait_for_nodes
 
+# Enable the transaction indexer and use a low search count cap, so that the
+# search tests can page past it.
+export OASIS_ROSETTA_GATEWAY_INDEXER="1"
+export OASIS_ROSETTA_GATEWAY_SEARCH_MAX_TOTAL_COUNT="2"
+# Keep the indices and the block event log on disk.
+export OASIS_ROSETTA_GATEWAY_DATA_DIR="${TEST_BASE_DIR}/gateway"
+# Track the transactions submitted through the gateway and rebroadcast them
+# if they are dropped from the mempool.
+export OASIS_ROSETTA_GATEWAY_TRACKER="1"
+export OASIS_ROSETTA_GATEWAY_REBROADCAST_RETRIES="3"
+# Only list the transactions of busy blocks in other_transactions.
+export OASIS_ROSETTA_GATEWAY_BLOCK_TX_LIMIT="1"
+
 printf "${GRN}### Starting the Rosetta gateway...${OFF}\n"
 ${OASIS_ROSETTA_GW} &
 
@@ -229,6 +243,62 @@ ${OASIS_GO} run ./construction-signing
 printf "${GRN}### Testing construction transaction types...${OFF}\n"
 ${OASIS_GO} run ./construction-txtypes
 
+printf "${GRN}### Testing signature verification...${OFF}\n"
+${OASIS_GO} run ./construction-combine
+
+printf "${GRN}### Testing fee estimation and fee policies...${OFF}\n"
+${OASIS_GO} run ./construction-fees
+
+printf "${GRN}### Testing batch construction...${OFF}\n"
+${OASIS_GO} run ./construction-batch
+
+printf "${GRN}### Testing reclaim escrow by amount...${OFF}\n"
+${OASIS_GO} run ./reclaim-amount
+
+printf "${GRN}### Testing submit and wait...${OFF}\n"
+${OASIS_GO} run ./submit-and-wait
+
+printf "${GRN}### Testing transaction metadata...${OFF}\n"
+${OASIS_GO} run ./tx-metadata
+
+printf "${GRN}### Testing failed calls...${OFF}\n"
+${OASIS_GO} run ./failed-call
+
+printf "${GRN}### Testing transaction tracking...${OFF}\n"
+${OASIS_GO} run ./tracker "${TEST_BASE_DIR}/tx1.json"
+
+printf "${GRN}### Testing account mempool listing...${OFF}\n"
+${OASIS_GO} run ./mempool-account
+
+printf "${GRN}### Testing block lookups...${OFF}\n"
+${OASIS_GO} run ./block
+
+printf "${GRN}### Testing transaction search...${OFF}\n"
+${OASIS_GO} run ./search
+
+printf "${GRN}### Testing block events...${OFF}\n"
+${OASIS_GO} run ./events
+
+printf "${GRN}### Testing slash operations...${OFF}\n"
+${OASIS_GO} run ./slash
+
+# Staking rewards are paid at the end of the epoch on the stake escrowed with
+# the validator's entity.
+VALIDATOR_ENTITY="${TEST_BASE_DIR}/net-runner/network/entity-1/entity.json"
+printf "${GRN}### Escrowing tokens with the validator...${OFF}\n"
+${OASIS_GO} run ./rewards escrow "${VALIDATOR_ENTITY}"
+
+# The stake that started debonding in the reclaim tests is reclaimed at the
+# next epoch.
+advance_epoch 7
+wait_for_nodes
+
+printf "${GRN}### Testing debonded escrow reclaims...${OFF}\n"
+${OASIS_GO} run ./reclaim-amount debonded
+
+printf "${GRN}### Testing staking rewards...${OFF}\n"
+${OASIS_GO} run ./rewards check "${VALIDATOR_ENTITY}"
+
 # Now test if the initial block height change works on a new network.
 printf "${GRN}### Terminating existing test network...${OFF}\n"
 cleanup
@@ -244,6 +314,14 @@ sleep 2
 advance_epoch 1
 wait_for_nodes
 
+# The indices of the previous network don't apply to the new one.
+unset OASIS_ROSETTA_GATEWAY_DATA_DIR
+
+# Reserve the nonces handed out by /construction/metadata.
+export OASIS_ROSETTA_GATEWAY_NONCE_RESERVATION_TTL="600"
+# Check transactions before submitting them.
+export OASIS_ROSETTA_GATEWAY_SUBMIT_PREFLIGHT="1"
+
 printf "${GRN}### Starting the Rosetta gateway (again)...${OFF}\n"
 ${OASIS_ROSETTA_GW} &
 
@@ -267,6 +345,12 @@ printf "${GRN}### Validating Rosetta gateway implementation (again)...${OFF}\n"
 ${OASIS_GO} run ./check-prep
 ./rosetta-cli --configuration-file rosetta-cli-config.json check:data
 
+printf "${GRN}### Testing pre-submission checks...${OFF}\n"
+${OASIS_GO} run ./preflight
+
+printf "${GRN}### Testing nonce reservation...${OFF}\n"
+${OASIS_GO} run ./nonce-reservation
+
 # Clean up after a successful run.
 printf "${GRN}### Terminating existing test network...${OFF}\n"
 cleanup
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+func status(ni *types.NetworkIdentifier, txHash string) (*services.ConstructionStatusResponse, *types.Error) {
+	var resp services.ConstructionStatusResponse
+	re := common.CallExtension("/construction/status", &services.ConstructionStatusRequest{
+		NetworkIdentifier: ni,
+		TransactionIdentifier: &types.TransactionIdentifier{
+			Hash: txHash,
+		},
+	}, &resp)
+	if re != nil {
+		return nil, re
+	}
+	fmt.Println("status", common.DumpJSON(&resp))
+	return &resp, nil
+}
+
+// waitForState waits until the given transaction reaches the given state.
+// The transaction must be in the pending state until then.
+func waitForState(ni *types.NetworkIdentifier, txHash string, state string) *services.ConstructionStatusResponse {
+	for i := 0; i < 60; i++ {
+		resp, re := status(ni, txHash)
+		if re != nil {
+			panic(fmt.Errorf("status of %s: %v", txHash, re))
+		}
+		switch resp.State {
+		case state:
+			return resp
+		case services.TxStatePending:
+		default:
+			panic(fmt.Errorf("status of %s: got state %s, expected %s", txHash, resp.State, state))
+		}
+		time.Sleep(time.Second)
+	}
+	panic(fmt.Errorf("status of %s: state %s not reached", txHash, state))
+}
+
+func submit(rc *client.APIClient, ni *types.NetworkIdentifier, signedTx string) string {
+	resp, re := common.Submit(rc, ni, signedTx)
+	if re != nil {
+		panic(fmt.Errorf("submit: %v", re))
+	}
+	return resp.TransactionIdentifier.Hash
+}
+
+// checkIncluded checks that the block in the given status includes the
+// transaction.
+func checkIncluded(rc *client.APIClient, ni *types.NetworkIdentifier, resp *services.ConstructionStatusResponse) {
+	if resp.BlockIdentifier == nil {
+		panic(fmt.Errorf("status of %s: missing block identifier", resp.TransactionIdentifier.Hash))
+	}
+	_, re, err := rc.BlockAPI.BlockTransaction(context.Background(), &types.BlockTransactionRequest{
+		NetworkIdentifier:     ni,
+		BlockIdentifier:       resp.BlockIdentifier,
+		TransactionIdentifier: resp.TransactionIdentifier,
+	})
+	if err != nil {
+		panic(fmt.Errorf("block transaction: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("block transaction: %v", re))
+	}
+}
+
+func expectError(name string, re *types.Error, expected *types.Error) {
+	if re == nil || re.Code != expected.Code {
+		panic(fmt.Errorf("%s: got error %v, expected code %d", name, re, expected.Code))
+	}
+	fmt.Println(name, "error", common.DumpJSON(re))
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	// Transactions submitted through the gateway are tracked until they are
+	// included in a block.
+	submitted := time.Now().UnixMilli()
+	txHash := submit(rc, ni, common.SignedTransfer(rc, ni, "1000"))
+	includedResp := waitForState(ni, txHash, services.TxStateIncluded)
+	if includedResp.SubmittedTimestamp < submitted || includedResp.Error != nil {
+		panic(fmt.Errorf("status of %s: unexpected status", txHash))
+	}
+	checkIncluded(rc, ni, includedResp)
+
+	// Failed transactions are tracked with their error.
+	r1 := common.AccountBalance(rc, ni, &types.AccountIdentifier{
+		Address: common.TestEntityAddressText,
+	})
+	balance, ok := new(big.Int).SetString(r1.Balances[0].Value, 10)
+	if !ok {
+		panic(fmt.Errorf("malformed balance %s", r1.Balances[0].Value))
+	}
+	tooMuch := new(big.Int).Add(balance, big.NewInt(1)).String()
+	txHash = submit(rc, ni, common.SignedTransfer(rc, ni, tooMuch))
+	failedResp := waitForState(ni, txHash, services.TxStateFailed)
+	for _, key := range []string{services.ModuleKey, services.CodeKey, services.MsgKey} {
+		if _, ok := failedResp.Error[key]; !ok {
+			panic(fmt.Errorf("status of %s: error %s missing", txHash, key))
+		}
+	}
+	checkIncluded(rc, ni, failedResp)
+
+	// Transactions submitted and waited for are included right away.
+	var swResp services.ConstructionSubmitAndWaitResponse
+	re := common.CallExtension("/construction/submit_and_wait", &services.ConstructionSubmitAndWaitRequest{
+		NetworkIdentifier: ni,
+		SignedTransaction: common.SignedTransfer(rc, ni, "1000"),
+	}, &swResp)
+	if re != nil {
+		panic(fmt.Errorf("submit and wait: %v", re))
+	}
+	resp, re := status(ni, swResp.TransactionIdentifier.Hash)
+	if re != nil {
+		panic(fmt.Errorf("status of %s: %v", swResp.TransactionIdentifier.Hash, re))
+	}
+	if resp.State != services.TxStateIncluded || resp.BlockIdentifier.Index != swResp.BlockIdentifier.Index {
+		panic(fmt.Errorf("status of %s: expected inclusion at height %d",
+			swResp.TransactionIdentifier.Hash, swResp.BlockIdentifier.Index))
+	}
+
+	// Transactions that were included are never rebroadcast, even once they
+	// are no longer in the mempool.
+	time.Sleep(3 * time.Second)
+	for _, included := range []*services.ConstructionStatusResponse{includedResp, failedResp} {
+		resp, re = status(ni, included.TransactionIdentifier.Hash)
+		if re != nil {
+			panic(fmt.Errorf("status of %s: %v", included.TransactionIdentifier.Hash, re))
+		}
+		if resp.State != included.State || resp.Rebroadcasts != 0 || resp.SubmittedTimestamp != included.SubmittedTimestamp {
+			panic(fmt.Errorf("status of %s: transaction rebroadcast", included.TransactionIdentifier.Hash))
+		}
+	}
+
+	// Transactions whose nonce was already used expire instead of being
+	// rebroadcast.  The node treats resubmissions of recently included
+	// transactions as duplicates, which the gateway tracks like new ones.
+	rawTx, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	var stx transaction.SignedTransaction
+	if err = json.Unmarshal(rawTx, &stx); err != nil {
+		panic(fmt.Errorf("malformed transaction file: %w", err))
+	}
+	txHash = submit(rc, ni, base64.StdEncoding.EncodeToString(cbor.Marshal(&stx)))
+	if resp = waitForState(ni, txHash, services.TxStateExpired); resp.Rebroadcasts != 0 {
+		panic(fmt.Errorf("status of %s: expired transaction rebroadcast", txHash))
+	}
+
+	// Other transactions aren't tracked.
+	unknown := hash.NewFromBytes([]byte("not submitted"))
+	_, re = status(ni, unknown.String())
+	expectError("unknown transaction", re, services.ErrTransactionNotTracked)
+	_, re = status(ni, "not a hash")
+	expectError("malformed hash", re, services.ErrMalformedValue)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/errors"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-rosetta-gateway/services"
+	"github.com/oasisprotocol/oasis-rosetta-gateway/tests/common"
+)
+
+// blockTransaction returns the given transaction as listed in /block, or as
+// returned by /block/transaction if the block only lists its identifier.
+func blockTransaction(
+	rc *client.APIClient,
+	ni *types.NetworkIdentifier,
+	blk *types.BlockIdentifier,
+	txID *types.TransactionIdentifier,
+) *types.Transaction {
+	resp, re, err := rc.BlockAPI.Block(context.Background(), &types.BlockRequest{
+		NetworkIdentifier: ni,
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Index: &blk.Index,
+		},
+	})
+	if err != nil {
+		panic(fmt.Errorf("block: %w", err))
+	}
+	if re != nil {
+		panic(fmt.Errorf("block: %v", re))
+	}
+	for _, tx := range resp.Block.Transactions {
+		if tx.TransactionIdentifier.Hash == txID.Hash {
+			fmt.Println("block transaction", common.DumpJSON(tx))
+			return tx
+		}
+	}
+	for _, other := range resp.OtherTransactions {
+		if other.Hash == txID.Hash {
+			return common.BlockTransaction(rc, ni, blk, txID)
+		}
+	}
+	panic(fmt.Errorf("block %d doesn't list transaction %s", blk.Index, txID.Hash))
+}
+
+func main() {
+	rc, ni := common.NewRosettaClient()
+
+	// Transfers above the balance pass the node's checks, so they are
+	// included in a block as failed transactions.
+	r1 := common.AccountBalance(rc, ni, &types.AccountIdentifier{
+		Address: common.TestEntityAddressText,
+	})
+	balance, ok := new(big.Int).SetString(r1.Balances[0].Value, 10)
+	if !ok {
+		panic(fmt.Errorf("malformed balance %s", r1.Balances[0].Value))
+	}
+	signedTx := common.SignedTransfer(rc, ni, new(big.Int).Add(balance, big.NewInt(1)).String())
+	resp := common.SubmitAndWait(ni, signedTx)
+	if resp.Success {
+		panic(fmt.Errorf("transfer above balance: expected a failed transaction"))
+	}
+
+	sigTx, err := services.DecodeSignedTransaction(signedTx)
+	if err != nil {
+		panic(err)
+	}
+	var tx transaction.Transaction
+	if err = cbor.Unmarshal(sigTx.Blob, &tx); err != nil {
+		panic(err)
+	}
+
+	// The metadata describes the transaction and why it failed.  JSON numbers
+	// are decoded as float64.
+	md := blockTransaction(rc, ni, resp.BlockIdentifier, resp.TransactionIdentifier).Metadata
+	for key, expected := range map[string]interface{}{
+		services.TxMethodKey:          string(api.MethodTransfer),
+		services.TxNonceKey:           float64(tx.Nonce),
+		services.TxGasLimitKey:        float64(tx.Fee.Gas),
+		services.TxSignerPublicKeyKey: sigTx.Signature.PublicKey.String(),
+	} {
+		if md[key] != expected {
+			panic(fmt.Errorf("transfer above balance: got %s %v, expected %v", key, md[key], expected))
+		}
+	}
+	txErr, ok := md[services.TxErrorKey].(map[string]interface{})
+	if !ok {
+		panic(fmt.Errorf("transfer above balance: malformed error %v", md[services.TxErrorKey]))
+	}
+	module, code := errors.Code(api.ErrInsufficientBalance)
+	if txErr[services.ModuleKey] != module || txErr[services.CodeKey] != float64(code) {
+		panic(fmt.Errorf("transfer above balance: got error %v, expected module %s code %d", txErr, module, code))
+	}
+	if msg, _ := txErr[services.MsgKey].(string); msg == "" {
+		panic(fmt.Errorf("transfer above balance: error message missing"))
+	}
+}